@@ -0,0 +1,275 @@
+package mpo
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+// ErrAlignmentFailed indicates that too few points could be matched between
+// the frames of a stereo pair to estimate their misalignment.
+var ErrAlignmentFailed = errors.New("not enough matching points to align stereo pair")
+
+// Alignment is the misalignment of the right frame of a stereo pair relative
+// to the left, as estimated and corrected by Align.
+//
+// A point at x, y in the left frame, measured in pixels from the centre of
+// the frame, is found in the right frame at
+//
+//	x' = Scale·(x·cos(Angle) − y·sin(Angle)) + disparity
+//	y' = Scale·(x·sin(Angle) + y·cos(Angle)) + DY
+//
+// where the horizontal disparity depends on the depth of the point.
+type Alignment struct {
+	// DY is the vertical offset in pixels, positive if the right frame's
+	// content sits lower.
+	DY float64
+
+	// Angle is the rotation in radians, positive if the right frame's
+	// content is turned clockwise.
+	Angle float64
+
+	// Scale is the magnification of the right frame's content.
+	Scale float64
+
+	// Matches is the number of point correspondences the estimate is
+	// based on, after discarding outliers.
+	Matches int
+}
+
+// String returns the alignment with the angle in degrees.
+func (a Alignment) String() string {
+	return fmt.Sprintf("dy %+.2fpx, rotation %+.3f°, scale %.4f (%d matches)",
+		a.DY, a.Angle*180/math.Pi, a.Scale, a.Matches)
+}
+
+const (
+	alignWidth      = 256 // width the frames are reduced to for matching
+	alignMinMatches = 8
+)
+
+// Align returns a copy of m rectified for vertical alignment: the rotation,
+// scale and vertical offset of the right frame relative to the left are
+// estimated by matching textured patches between the frames, the right
+// frame is resampled to undo them, and both frames are cropped, keeping
+// their aspect ratio, to the centred area the corrected right frame covers.
+// Horizontal offsets are left alone, as they carry the depth of the scene;
+// ShiftParallax adjusts them.
+//
+// The estimated misalignment is returned along with the result, which holds
+// only the frames of m's DefaultPair. ErrAlignmentFailed is returned if the
+// frames have too little texture in common, and the errors of
+// ConvertToAnaglyph if m has no pair of frames of the same size.
+func (m *MPO) Align() (*MPO, Alignment, error) {
+	left, right, err := m.stereoPair()
+	if err != nil {
+		return nil, Alignment{}, err
+	}
+
+	a, err := estimateAlignment(left, right)
+	if err != nil {
+		return nil, Alignment{}, err
+	}
+
+	b := left.Bounds()
+	warp := func(x, y float64) (float64, float64) {
+		sin, cos := math.Sincos(a.Angle)
+		return a.Scale * (x*cos - y*sin), a.Scale*(x*sin+y*cos) + a.DY
+	}
+	r := coveredRect(b, warp)
+	if r.Empty() {
+		return nil, a, fmt.Errorf("alignment %v leaves no area common to both frames", a)
+	}
+
+	return &MPO{Image: []image.Image{crop(left, r), resample(right, r, warp)}}, a, nil
+}
+
+// estimateAlignment matches points between left and right, which share
+// bounds, and fits the vertical component of the Alignment model to them.
+func estimateAlignment(left, right image.Image) (Alignment, error) {
+	l, factor := toGray(left, alignWidth)
+	r, _ := toGray(right, alignWidth)
+
+	ms := matchFeatures(l, r, l.w/5, max(l.h/12, 2))
+	if len(ms) < alignMinMatches {
+		return Alignment{}, fmt.Errorf("%d matches: %w", len(ms), ErrAlignmentFailed)
+	}
+
+	// y' = p·x + q·y + t in coordinates centred on the frame, with
+	// p = Scale·sin(Angle) and q = Scale·cos(Angle)
+	cx, cy := float64(l.w-1)/2, float64(l.h-1)/2
+	var p, q, t float64
+	for range 4 {
+		var ata [3][3]float64
+		var atb [3]float64
+		for _, mt := range ms {
+			row := [3]float64{mt.x - cx, mt.y - cy, 1}
+			for i := range 3 {
+				for j := range 3 {
+					ata[i][j] += row[i] * row[j]
+				}
+				atb[i] += row[i] * (mt.y - cy + mt.dy)
+			}
+		}
+
+		sol, ok := solve3(ata, atb)
+		if !ok {
+			return Alignment{}, fmt.Errorf("matches are degenerate: %w", ErrAlignmentFailed)
+		}
+		p, q, t = sol[0], sol[1], sol[2]
+
+		// discard matches far from the fit, by median absolute deviation
+		res := make([]float64, len(ms))
+		for i, mt := range ms {
+			res[i] = math.Abs(p*(mt.x-cx) + q*(mt.y-cy) + t - (mt.y - cy + mt.dy))
+		}
+		sorted := append([]float64(nil), res...)
+		sort.Float64s(sorted)
+		limit := max(3*1.4826*sorted[len(sorted)/2], 0.25)
+
+		kept := ms[:0:0]
+		for i, mt := range ms {
+			if res[i] <= limit {
+				kept = append(kept, mt)
+			}
+		}
+		if len(kept) < alignMinMatches {
+			return Alignment{}, fmt.Errorf("%d consistent matches: %w", len(kept), ErrAlignmentFailed)
+		}
+		if len(kept) == len(ms) {
+			break
+		}
+		ms = kept
+	}
+
+	return Alignment{
+		DY:      t * factor,
+		Angle:   math.Atan2(p, q),
+		Scale:   math.Hypot(p, q),
+		Matches: len(ms),
+	}, nil
+}
+
+// solve3 solves the 3×3 linear system a·x = b by Gaussian elimination with
+// partial pivoting. ok is false if a is singular.
+func solve3(a [3][3]float64, b [3]float64) (x [3]float64, ok bool) {
+	for c := range 3 {
+		pivot := c
+		for r := c + 1; r < 3; r++ {
+			if math.Abs(a[r][c]) > math.Abs(a[pivot][c]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][c]) < 1e-9 {
+			return x, false
+		}
+		a[c], a[pivot] = a[pivot], a[c]
+		b[c], b[pivot] = b[pivot], b[c]
+
+		for r := c + 1; r < 3; r++ {
+			f := a[r][c] / a[c][c]
+			for k := c; k < 3; k++ {
+				a[r][k] -= f * a[c][k]
+			}
+			b[r] -= f * b[c]
+		}
+	}
+
+	for r := 2; r >= 0; r-- {
+		v := b[r]
+		for k := r + 1; k < 3; k++ {
+			v -= a[r][k] * x[k]
+		}
+		x[r] = v / a[r][r]
+	}
+
+	return x, true
+}
+
+// coveredRect returns the largest rectangle within b, centred on it and of
+// the same aspect ratio, whose corners warp maps inside b. warp works in
+// coordinates relative to the centre of b.
+func coveredRect(b image.Rectangle, warp func(x, y float64) (float64, float64)) image.Rectangle {
+	hw, hh := float64(b.Dx())/2, float64(b.Dy())/2
+	inside := func(k float64) bool {
+		for _, c := range [][2]float64{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}} {
+			x, y := warp(c[0]*k*hw, c[1]*k*hh)
+			if math.Abs(x) > hw+1e-9 || math.Abs(y) > hh+1e-9 {
+				return false
+			}
+		}
+		return true
+	}
+
+	lo, hi := 0.0, 1.0
+	if !inside(hi) {
+		for range 32 {
+			if mid := (lo + hi) / 2; inside(mid) {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		hi = lo
+	}
+
+	dx, dy := int(math.Ceil(hw*(1-hi))), int(math.Ceil(hh*(1-hi)))
+	return image.Rect(b.Min.X+dx, b.Min.Y+dy, b.Max.X-dx, b.Max.Y-dy)
+}
+
+// resample returns the pixels of img within r, as the bilinear interpolation
+// of img at the positions warp maps them to. warp works in coordinates
+// relative to the centre of img's bounds.
+func resample(img image.Image, r image.Rectangle, warp func(x, y float64) (float64, float64)) *image.RGBA {
+	src := toRGBA(img)
+	b := src.Bounds()
+
+	cx := float64(b.Min.X) + float64(b.Dx())/2
+	cy := float64(b.Min.Y) + float64(b.Dy())/2
+	dst := image.NewRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			// pixel centres are at half coordinates
+			sx, sy := warp(float64(x)+0.5-cx, float64(y)+0.5-cy)
+			d := dst.PixOffset(x, y)
+			bilinear(dst.Pix[d:d+4], src, sx+cx-0.5, sy+cy-0.5)
+		}
+	}
+
+	return dst
+}
+
+// toRGBA returns img as an *image.RGBA, converting it if need be.
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+
+	b := img.Bounds()
+	rgba := image.NewRGBA(b)
+	draw.Draw(rgba, b, img, b.Min, draw.Src)
+
+	return rgba
+}
+
+// bilinear sets px to the bilinear interpolation of src at x, y, measured
+// between pixel centres, clamping to the edges of src.
+func bilinear(px []uint8, src *image.RGBA, x, y float64) {
+	b := src.Bounds()
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+	x0 = max(b.Min.X, min(x0, b.Max.X-1))
+	y0 = max(b.Min.Y, min(y0, b.Max.Y-1))
+	x1, y1 := min(x0+1, b.Max.X-1), min(y0+1, b.Max.Y-1)
+
+	p00, p10 := src.PixOffset(x0, y0), src.PixOffset(x1, y0)
+	p01, p11 := src.PixOffset(x0, y1), src.PixOffset(x1, y1)
+	for c := range 4 {
+		top := float64(src.Pix[p00+c])*(1-fx) + float64(src.Pix[p10+c])*fx
+		bottom := float64(src.Pix[p01+c])*(1-fx) + float64(src.Pix[p11+c])*fx
+		px[c] = uint8(top*(1-fy) + bottom*fy + 0.5)
+	}
+}
@@ -0,0 +1,98 @@
+package mpo_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+// texture returns a smooth, non-repeating gray pattern defined at any point.
+func texture(seed int64) func(x, y float64) float64 {
+	rnd := rand.New(rand.NewSource(seed))
+	type wave struct{ fx, fy, phase, amp float64 }
+	waves := make([]wave, 40)
+	for i := range waves {
+		wl := 6 + rnd.Float64()*50
+		dir := rnd.Float64() * 2 * math.Pi
+		waves[i] = wave{math.Cos(dir) / wl, math.Sin(dir) / wl, rnd.Float64() * 2 * math.Pi, 0.5 + rnd.Float64()}
+	}
+	return func(x, y float64) float64 {
+		var v float64
+		for _, w := range waves {
+			v += w.amp * math.Sin(2*math.Pi*(w.fx*x+w.fy*y)+w.phase)
+		}
+		return 128 + 12*v
+	}
+}
+
+// misalignedPair renders the texture as a left frame and as a right frame
+// with the given misalignment and a uniform disparity of 12 pixels.
+func misalignedPair(w, h int, a mpo.Alignment) *mpo.MPO {
+	tex := texture(1)
+	left := image.NewGray(image.Rect(0, 0, w, h))
+	right := image.NewGray(image.Rect(0, 0, w, h))
+	sin, cos := math.Sincos(a.Angle)
+	cx, cy := float64(w)/2, float64(h)/2
+	for y := range h {
+		for x := range w {
+			px, py := float64(x)+0.5-cx, float64(y)+0.5-cy
+			left.SetGray(x, y, color.Gray{uint8(tex(px, py))})
+
+			// invert x' = s·R·p + (12, DY)
+			qx, qy := (px-12)/a.Scale, (py-a.DY)/a.Scale
+			right.SetGray(x, y, color.Gray{uint8(tex(qx*cos+qy*sin, -qx*sin+qy*cos))})
+		}
+	}
+	return &mpo.MPO{Image: []image.Image{left, right}}
+}
+
+func TestAlign(t *testing.T) {
+	want := mpo.Alignment{DY: 7, Angle: 0.8 * math.Pi / 180, Scale: 1.015}
+	m := misalignedPair(640, 480, want)
+
+	out, got, err := m.Align()
+	if err != nil {
+		t.Fatalf("Align failed: %v", err)
+	}
+	t.Log(got)
+
+	if math.Abs(got.DY-want.DY) > 0.5 {
+		t.Errorf("DY = %.2f, want %.2f", got.DY, want.DY)
+	}
+	if math.Abs(got.Angle-want.Angle) > 0.1*math.Pi/180 {
+		t.Errorf("Angle = %.4f, want %.4f", got.Angle, want.Angle)
+	}
+	if math.Abs(got.Scale-want.Scale) > 0.003 {
+		t.Errorf("Scale = %.4f, want %.4f", got.Scale, want.Scale)
+	}
+
+	l, r := out.Image[0].Bounds(), out.Image[1].Bounds()
+	if !l.Eq(r) {
+		t.Fatalf("frame bounds differ: %v and %v", l, r)
+	}
+	if l.Dx() >= 640 || l.Dy() >= 480 {
+		t.Errorf("frames not cropped: %v", l)
+	}
+
+	// rectified, the pair differs only by the disparity
+	_, residual, err := out.Align()
+	if err != nil {
+		t.Fatalf("Align of rectified pair failed: %v", err)
+	}
+	if math.Abs(residual.DY) > 0.5 || math.Abs(residual.Angle) > 0.05*math.Pi/180 || math.Abs(residual.Scale-1) > 0.002 {
+		t.Errorf("rectified pair still misaligned: %v", residual)
+	}
+}
+
+func TestAlignFlat(t *testing.T) {
+	flat := image.NewGray(image.Rect(0, 0, 200, 100))
+	m := &mpo.MPO{Image: []image.Image{flat, flat}}
+	if _, _, err := m.Align(); !errors.Is(err, mpo.ErrAlignmentFailed) {
+		t.Errorf("Align of flat frames: err = %v, want ErrAlignmentFailed", err)
+	}
+}
@@ -5,13 +5,15 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"math"
 )
 
-type colorType int
+// ColorType is the colours of the filters of a pair of anaglyph glasses.
+type ColorType int
 
 const (
 	// RedCyan is Red on left eye, cyan on right
-	RedCyan colorType = iota
+	RedCyan ColorType = iota
 
 	// CyanRed is Cyan on left eye, red on right
 	CyanRed
@@ -21,39 +23,349 @@ const (
 
 	// GreenRed is Green on left eye, red on right
 	GreenRed
+
+	// AmberBlue is Amber on left eye, blue on right, as for ColorCode 3-D
+	// glasses
+	AmberBlue
+
+	// BlueAmber is Blue on left eye, amber on right
+	BlueAmber
+
+	// GreenMagenta is Green on left eye, magenta on right
+	GreenMagenta
+
+	// MagentaGreen is Magenta on left eye, green on right, as for
+	// TrioScopics glasses
+	MagentaGreen
+)
+
+// AnaglyphMethod selects how ConvertToAnaglyphWithOptions mixes the frames
+// into the channels passed by each filter of the glasses.
+type AnaglyphMethod int
+
+const (
+	// DefaultAnaglyph is the mix ConvertToAnaglyph has always made:
+	// HalfColorAnaglyph, with any channel neither filter passes, such as
+	// blue for red-green glasses, at half intensity.
+	DefaultAnaglyph AnaglyphMethod = iota
+
+	// TrueAnaglyph shows each frame in grey in a single channel its filter
+	// passes, blue before red before green, leaving the others dark. It
+	// has little ghosting but a dark, colourless result.
+	TrueAnaglyph
+
+	// GrayAnaglyph shows each frame in grey in every channel its filter
+	// passes.
+	GrayAnaglyph
+
+	// ColorAnaglyph shows each frame's own colour in every channel its
+	// filter passes. It keeps the most colour but suffers from retinal
+	// rivalry.
+	ColorAnaglyph
+
+	// HalfColorAnaglyph shows a frame in grey if its filter passes a single
+	// channel and in colour otherwise, reducing retinal rivalry.
+	HalfColorAnaglyph
+
+	// OptimizedAnaglyph is HalfColorAnaglyph except that a frame seen
+	// through a red filter is made from its green and blue channels,
+	// avoiding the rivalry bright reds cause.
+	OptimizedAnaglyph
+
+	// DuboisAnaglyph uses Dubois' least-squares projection, which is only
+	// available for red-cyan, green-magenta and amber-blue glasses and
+	// their reverses.
+	DuboisAnaglyph
 )
 
-// ErrInvalidImageCount indicates that incorrect number of images were found
-// during the anaglyph conversion process.
-var ErrInvalidImageCount = errors.New("anaglyph conversion only supports 2 images")
+// AnaglyphOptions are the parameters used by ConvertToAnaglyphWithOptions.
+type AnaglyphOptions struct {
+	// Colors are the colours of the glasses' filters.
+	Colors ColorType
+
+	// Method mixes the frames into the channels each filter passes.
+	Method AnaglyphMethod
+
+	// Matrix, if not nil, mixes the frames instead of Colors and Method,
+	// for glasses the built-in types do not suit.
+	Matrix *AnaglyphMatrix
+}
+
+// AnaglyphMatrix mixes the frames of a stereo pair into an anaglyph: each
+// red, green and blue channel of the anaglyph, in rows of Left and Right, is
+// the sum of the red, green and blue channels of the left frame weighted by
+// its row of Left and of the right frame weighted by its row of Right. The
+// results are clamped to the valid range.
+//
+// For example, the colour red-cyan anaglyph is
+//
+//	AnaglyphMatrix{
+//		Left:  [3][3]float64{{1, 0, 0}, {0, 0, 0}, {0, 0, 0}},
+//		Right: [3][3]float64{{0, 0, 0}, {0, 1, 0}, {0, 0, 1}},
+//	}
+type AnaglyphMatrix struct {
+	Left, Right [3][3]float64
+}
+
+// matrix returns a as an anaglyphMatrix.
+func (a *AnaglyphMatrix) matrix() (*anaglyphMatrix, error) {
+	var m anaglyphMatrix
+	for c := range 3 {
+		for i := range 3 {
+			l, r := a.Left[c][i], a.Right[c][i]
+			if math.IsNaN(l) || math.IsInf(l, 0) || math.IsNaN(r) || math.IsInf(r, 0) {
+				return nil, fmt.Errorf("invalid anaglyph matrix weight in row %d: %g, %g", c, l, r)
+			}
+			m.left[c][i], m.right[c][i] = float32(l), float32(r)
+		}
+	}
+
+	return &m, nil
+}
+
+// anaglyphMatrix computes each channel of an anaglyph as the weighted sum of
+// the red, green and blue channels of the left and right frames, in rows of
+// left and right, plus offset.
+type anaglyphMatrix struct {
+	left, right [3][3]float32
+	offset      [3]float32
+}
+
+// luma holds the Rec. 601 luma weights as a matrix row.
+var luma = [3]float32{.299, .587, .114}
+
+// anaglyphColors describes a ColorType: the channels passed by the left and
+// right filters and, if it has one, its Dubois matrix.
+type anaglyphColors struct {
+	left, right [3]bool
+	dubois      *anaglyphMatrix
+}
+
+// channels passed by each colour of filter
+var (
+	filterRed     = [3]bool{true, false, false}
+	filterGreen   = [3]bool{false, true, false}
+	filterCyan    = [3]bool{false, true, true}
+	filterMagenta = [3]bool{true, false, true}
+	filterAmber   = [3]bool{true, true, false}
+	filterBlue    = [3]bool{false, false, true}
+)
+
+// E. Dubois, "A projection method to generate anaglyph stereo images",
+// ICASSP 2001, and the matrices published on his site since.
+var (
+	duboisRedCyan = anaglyphMatrix{
+		left: [3][3]float32{
+			{.456, .500, .176},
+			{-.040, -.038, -.016},
+			{-.015, -.021, -.005},
+		},
+		right: [3][3]float32{
+			{-.043, -.088, -.002},
+			{.378, .734, -.018},
+			{-.072, -.113, 1.226},
+		},
+	}
+	duboisGreenMagenta = anaglyphMatrix{
+		left: [3][3]float32{
+			{-.062, -.158, -.039},
+			{.284, .668, .143},
+			{-.015, -.027, .021},
+		},
+		right: [3][3]float32{
+			{.529, .705, .024},
+			{-.016, -.015, -.065},
+			{.009, .075, .937},
+		},
+	}
+	duboisAmberBlue = anaglyphMatrix{
+		left: [3][3]float32{
+			{1.062, -.205, .299},
+			{-.026, .908, .068},
+			{-.038, -.173, .022},
+		},
+		right: [3][3]float32{
+			{-.016, -.123, -.017},
+			{.006, .062, -.017},
+			{.094, .185, .911},
+		},
+	}
+)
+
+var anaglyphColorTypes = map[ColorType]anaglyphColors{
+	RedCyan:      {left: filterRed, right: filterCyan, dubois: &duboisRedCyan},
+	CyanRed:      {left: filterCyan, right: filterRed, dubois: swapEyes(duboisRedCyan)},
+	RedGreen:     {left: filterRed, right: filterGreen},
+	GreenRed:     {left: filterGreen, right: filterRed},
+	AmberBlue:    {left: filterAmber, right: filterBlue, dubois: &duboisAmberBlue},
+	BlueAmber:    {left: filterBlue, right: filterAmber, dubois: duboisBlueAmber},
+	GreenMagenta: {left: filterGreen, right: filterMagenta, dubois: &duboisGreenMagenta},
+	MagentaGreen: {left: filterMagenta, right: filterGreen, dubois: duboisMagentaGreen},
+}
+
+// Dubois matrices of the reversed glasses
+var (
+	duboisBlueAmber    = swapEyes(duboisAmberBlue)
+	duboisMagentaGreen = swapEyes(duboisGreenMagenta)
+)
+
+// swapEyes returns a with the left and right frames exchanged, for glasses
+// with the filters the other way round.
+func swapEyes(a anaglyphMatrix) *anaglyphMatrix {
+	a.left, a.right = a.right, a.left
+	return &a
+}
+
+// anaglyphMatrixFor returns the matrix mixing the frames by method for
+// glasses of colours ct.
+func anaglyphMatrixFor(ct ColorType, method AnaglyphMethod) (*anaglyphMatrix, error) {
+	c, ok := anaglyphColorTypes[ct]
+	if !ok {
+		return nil, fmt.Errorf("unsupported color type %d: %w", ct, ErrUnsupportedColorType)
+	}
+
+	switch method {
+	case DefaultAnaglyph:
+		a := &anaglyphMatrix{
+			left:  filterMatrix(c.left, HalfColorAnaglyph),
+			right: filterMatrix(c.right, HalfColorAnaglyph),
+		}
+		for ch := range a.offset {
+			if !c.left[ch] && !c.right[ch] {
+				a.offset[ch] = 65535 / 2
+			}
+		}
+		return a, nil
+	case DuboisAnaglyph:
+		if c.dubois == nil {
+			return nil, fmt.Errorf("no Dubois matrix for color type %d: %w", ct, ErrUnsupportedAnaglyphMethod)
+		}
+		return c.dubois, nil
+	case TrueAnaglyph, GrayAnaglyph, ColorAnaglyph, HalfColorAnaglyph, OptimizedAnaglyph:
+		return &anaglyphMatrix{
+			left:  filterMatrix(c.left, method),
+			right: filterMatrix(c.right, method),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported anaglyph method %d: %w", method, ErrUnsupportedAnaglyphMethod)
+}
+
+// filterMatrix returns the rows mixing a frame by method into the channels
+// passed by filter.
+func filterMatrix(filter [3]bool, method AnaglyphMethod) [3][3]float32 {
+	var rows [3][3]float32
+	if method == TrueAnaglyph {
+		// blue, red then green
+		for _, c := range []int{2, 0, 1} {
+			if filter[c] {
+				rows[c] = luma
+				break
+			}
+		}
+		return rows
+	}
+
+	passed := 0
+	for _, p := range filter {
+		if p {
+			passed++
+		}
+	}
+	gray := method == GrayAnaglyph || passed == 1 && method != ColorAnaglyph
+
+	for c, p := range filter {
+		switch {
+		case !p:
+		case method == OptimizedAnaglyph && filter == filterRed:
+			rows[c] = [3]float32{0, .7, .3}
+		case gray:
+			rows[c] = luma
+		default:
+			rows[c][c] = 1
+		}
+	}
+
+	return rows
+}
+
+// apply returns the anaglyph colour of the left and right colours l and r,
+// given as 16-bit channels, clamped to the valid range.
+func (a *anaglyphMatrix) apply(l, r [3]float32) color.RGBA64 {
+	var out [3]uint16
+	for c := range out {
+		var v float32
+		for i := range 3 {
+			v += a.left[c][i] * l[i]
+		}
+		for i := range 3 {
+			v += a.right[c][i] * r[i]
+		}
+		v += a.offset[c]
+		out[c] = uint16(max(0, min(v, 65535)))
+	}
+
+	return color.RGBA64{R: out[0], G: out[1], B: out[2], A: 65535}
+}
+
+// ErrInvalidImageCount indicates that an MPO has fewer than the 2 images a
+// stereo conversion or format requires.
+var ErrInvalidImageCount = errors.New("at least 2 images are required")
 
 // ErrInconsistentBounds indicates that not all images within the MPO file were
-// found to be the same size, which is a requirement for the anaglyph conversion.
-var ErrInconsistentBounds = errors.New("anaglyph images must be the same size")
+// found to be the same size, which is a requirement for the anaglyph,
+// interleave, lenticular and wiggle conversions.
+var ErrInconsistentBounds = errors.New("images must be the same size")
 
 // ErrUnsupportedColorType indicates that the color type requested is not
 // supported by the anaglyph conversion process.
 var ErrUnsupportedColorType = errors.New("unsupported color type")
 
-// ConvertToAnaglyph converts an MPO to the anaglyph format specified by ct colorType constant
-// and returns the resulting image.
+// ErrUnsupportedAnaglyphMethod indicates that the anaglyph method requested
+// is not supported, or not for the color type requested.
+var ErrUnsupportedAnaglyphMethod = errors.New("unsupported anaglyph method")
+
+// ConvertToAnaglyph converts an MPO to the anaglyph format specified by ct ColorType constant
+// and returns the resulting image, mixed by the DefaultAnaglyph method.
+// ConvertToAnaglyphWithOptions can combine any colour type with another
+// AnaglyphMethod, such as DuboisAnaglyph, which gives the best results with
+// matching glasses. With more than 2 frames, the DefaultPair is used.
 //
 // ErrInconsistentBounds is returned if the images within the MPO are not the same size.
-// ErrInvalidImageCount is returned if the number of images in the MPO is not exactly 2.
+// ErrInvalidImageCount is returned if the MPO has fewer than 2 images.
 // ErrUnsupportedColorType is returned if the color type requested is not supported.
-func (m *MPO) ConvertToAnaglyph(ct colorType) (image.Image, error) {
-	if len(m.Image) != 2 {
-		return nil, ErrInvalidImageCount
-	}
+func (m *MPO) ConvertToAnaglyph(ct ColorType) (image.Image, error) {
+	return m.ConvertToAnaglyphWithOptions(&AnaglyphOptions{Colors: ct})
+}
 
-	left := m.Image[0]
-	right := m.Image[1]
+// ConvertToAnaglyphWithOptions converts an MPO to an anaglyph for glasses
+// of the colours o.Colors, mixing the frames by o.Method, or by o.Matrix if
+// given. A nil o behaves as ConvertToAnaglyph(RedCyan).
+//
+// ErrInconsistentBounds, ErrInvalidImageCount and ErrUnsupportedColorType
+// are returned as by ConvertToAnaglyph. ErrUnsupportedAnaglyphMethod is
+// returned if the method is not supported, or not for the colours.
+func (m *MPO) ConvertToAnaglyphWithOptions(o *AnaglyphOptions) (image.Image, error) {
+	if o == nil {
+		o = &AnaglyphOptions{}
+	}
 
-	b := left.Bounds()
+	left, right, err := m.stereoPair()
+	if err != nil {
+		return nil, err
+	}
 
-	if !left.Bounds().Eq(right.Bounds()) {
-		return nil, ErrInconsistentBounds
+	var a *anaglyphMatrix
+	if o.Matrix != nil {
+		a, err = o.Matrix.matrix()
+	} else {
+		a, err = anaglyphMatrixFor(o.Colors, o.Method)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	b := left.Bounds()
 
 	img := image.NewRGBA(b)
 
@@ -62,47 +374,54 @@ func (m *MPO) ConvertToAnaglyph(ct colorType) (image.Image, error) {
 			lr, lg, lb, _ := left.At(x, y).RGBA()
 			rr, rg, rb, _ := right.At(x, y).RGBA()
 
-			lgs := float32(lr)*.299 + float32(lg)*.587 + float32(lb)*.114
-
-			rgs := float32(rr)*.299 + float32(rg)*.587 + float32(rb)*.114
-
-			var c color.RGBA64
-			switch ct {
-			case RedCyan:
-				c = color.RGBA64{
-					R: uint16(lgs),
-					G: uint16(rg),
-					B: uint16(rb),
-					A: 65535,
-				}
-			case CyanRed:
-				c = color.RGBA64{
-					R: uint16(rgs),
-					G: uint16(lg),
-					B: uint16(lb),
-					A: 65535,
-				}
-			case RedGreen:
-				c = color.RGBA64{
-					R: uint16(lgs),
-					G: uint16(rgs),
-					B: 65535 / 2,
-					A: 65535,
-				}
-			case GreenRed:
-				c = color.RGBA64{
-					R: uint16(rgs),
-					G: uint16(lgs),
-					B: 65535 / 2,
-					A: 65535,
-				}
-			default:
-				return nil, fmt.Errorf("unsupported color type %d: %w", ct, ErrUnsupportedColorType)
-			}
-
+			c := a.apply(
+				[3]float32{float32(lr), float32(lg), float32(lb)},
+				[3]float32{float32(rr), float32(rg), float32(rb)},
+			)
 			img.Set(x, y, c)
 		}
 	}
 
 	return img, nil
 }
+
+// stereoPair returns the left and right frames of m's DefaultPair, with the
+// right frame translated if need be so both share the same bounds, as frames
+// cropped by ShiftParallax do not.
+//
+// ErrInvalidImageCount is returned if the MPO has fewer than 2 images.
+// ErrInconsistentBounds is returned if the images within the MPO are not the same size.
+func (m *MPO) stereoPair() (left, right image.Image, err error) {
+	if len(m.Image) < 2 {
+		return nil, nil, ErrInvalidImageCount
+	}
+
+	l, r := m.DefaultPair()
+	left = m.Image[l]
+	right = m.Image[r]
+
+	lb, rb := left.Bounds(), right.Bounds()
+	if lb.Size() != rb.Size() {
+		return nil, nil, ErrInconsistentBounds
+	}
+	if lb.Min != rb.Min {
+		right = &translated{Image: right, offset: rb.Min.Sub(lb.Min)}
+	}
+
+	return left, right, nil
+}
+
+// translated is an image moved so its pixel at p is the pixel of Image at
+// p+offset.
+type translated struct {
+	image.Image
+	offset image.Point
+}
+
+func (t *translated) Bounds() image.Rectangle {
+	return t.Image.Bounds().Sub(t.offset)
+}
+
+func (t *translated) At(x, y int) color.Color {
+	return t.Image.At(x+t.offset.X, y+t.offset.Y)
+}
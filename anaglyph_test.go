@@ -0,0 +1,197 @@
+package mpo_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+func TestConvertToAnaglyph_Dubois(t *testing.T) {
+	white, black := color.RGBA{255, 255, 255, 255}, color.RGBA{0, 0, 0, 255}
+
+	tests := []struct {
+		name        string
+		left, right color.RGBA
+		colors      mpo.ColorType
+		want        color.RGBA64
+	}{
+		{
+			name: "red-cyan left", left: white, right: black,
+			colors: mpo.RedCyan,
+			want:   color.RGBA64{65535, 0, 0, 65535},
+		},
+		{
+			name: "red-cyan right", left: black, right: white,
+			colors: mpo.RedCyan,
+			want:   color.RGBA64{0, 65535, 65535, 65535},
+		},
+		{
+			name: "green-magenta left", left: white, right: black,
+			colors: mpo.GreenMagenta,
+			want:   color.RGBA64{0, 65535, 0, 65535},
+		},
+		{
+			name: "green-magenta right", left: black, right: white,
+			colors: mpo.GreenMagenta,
+			want:   color.RGBA64{65535, 0, 65535, 65535},
+		},
+		{
+			// the amber filter passes .95 of the left frame's white as green
+			name: "amber-blue left", left: white, right: black,
+			colors: mpo.AmberBlue,
+			want:   color.RGBA64{65535, 62258, 0, 65535},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &mpo.MPO{Image: []image.Image{solid(2, 2, tc.left), solid(2, 2, tc.right)}}
+			img, err := m.ConvertToAnaglyphWithOptions(&mpo.AnaglyphOptions{Colors: tc.colors, Method: mpo.DuboisAnaglyph})
+			if err != nil {
+				t.Fatalf("ConvertToAnaglyphWithOptions failed: %v", err)
+			}
+
+			// the result is stored with 8 bits per channel
+			got := color.RGBA64Model.Convert(img.At(1, 1)).(color.RGBA64)
+			if !near(got.R, tc.want.R) || !near(got.G, tc.want.G) || !near(got.B, tc.want.B) {
+				t.Errorf("anaglyph = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConvertToAnaglyph_UnsupportedColorType(t *testing.T) {
+	m := &mpo.MPO{Image: []image.Image{solid(2, 2, color.White), solid(2, 2, color.Black)}}
+	if _, err := m.ConvertToAnaglyph(mpo.ColorType(-1)); !errors.Is(err, mpo.ErrUnsupportedColorType) {
+		t.Errorf("ConvertToAnaglyph of an unknown type = %v, want ErrUnsupportedColorType", err)
+	}
+}
+
+// near reports whether a and b differ by no more than one 8-bit step.
+func near(a, b uint16) bool {
+	return max(a, b)-min(a, b) <= 257
+}
+
+func TestConvertToAnaglyphWithOptions(t *testing.T) {
+	left, right := color.RGBA{200, 100, 50, 255}, color.RGBA{20, 140, 220, 255}
+	m := &mpo.MPO{Image: []image.Image{solid(2, 2, left), solid(2, 2, right)}}
+
+	// luma of the left frame is 124.2, of the right 113.2
+	tests := []struct {
+		name string
+		opts mpo.AnaglyphOptions
+		want color.RGBA
+	}{
+		{"true red-cyan", mpo.AnaglyphOptions{Colors: mpo.RedCyan, Method: mpo.TrueAnaglyph}, color.RGBA{124, 0, 113, 255}},
+		{"gray red-cyan", mpo.AnaglyphOptions{Colors: mpo.RedCyan, Method: mpo.GrayAnaglyph}, color.RGBA{124, 113, 113, 255}},
+		{"color red-cyan", mpo.AnaglyphOptions{Colors: mpo.RedCyan, Method: mpo.ColorAnaglyph}, color.RGBA{200, 140, 220, 255}},
+		{"half-color red-cyan", mpo.AnaglyphOptions{Colors: mpo.RedCyan, Method: mpo.HalfColorAnaglyph}, color.RGBA{124, 140, 220, 255}},
+		{"half-color cyan-red", mpo.AnaglyphOptions{Colors: mpo.CyanRed, Method: mpo.HalfColorAnaglyph}, color.RGBA{113, 100, 50, 255}},
+		{"optimized red-cyan", mpo.AnaglyphOptions{Colors: mpo.RedCyan, Method: mpo.OptimizedAnaglyph}, color.RGBA{85, 140, 220, 255}},
+		{"gray red-green", mpo.AnaglyphOptions{Colors: mpo.RedGreen, Method: mpo.GrayAnaglyph}, color.RGBA{124, 113, 0, 255}},
+		{"color green-magenta", mpo.AnaglyphOptions{Colors: mpo.GreenMagenta, Method: mpo.ColorAnaglyph}, color.RGBA{20, 100, 220, 255}},
+		{"true amber-blue", mpo.AnaglyphOptions{Colors: mpo.AmberBlue, Method: mpo.TrueAnaglyph}, color.RGBA{124, 0, 113, 255}},
+		{"default red-cyan", mpo.AnaglyphOptions{Colors: mpo.RedCyan}, color.RGBA{124, 140, 220, 255}},
+		{"default red-green", mpo.AnaglyphOptions{Colors: mpo.RedGreen}, color.RGBA{124, 113, 127, 255}},
+		{"default amber-blue", mpo.AnaglyphOptions{Colors: mpo.AmberBlue}, color.RGBA{200, 100, 113, 255}},
+		{"default green-magenta", mpo.AnaglyphOptions{Colors: mpo.GreenMagenta}, color.RGBA{20, 124, 220, 255}},
+		{"default blue-amber", mpo.AnaglyphOptions{Colors: mpo.BlueAmber}, color.RGBA{20, 140, 124, 255}},
+		{"default magenta-green", mpo.AnaglyphOptions{Colors: mpo.MagentaGreen}, color.RGBA{200, 113, 50, 255}},
+		{"half-color magenta-green", mpo.AnaglyphOptions{Colors: mpo.MagentaGreen, Method: mpo.HalfColorAnaglyph}, color.RGBA{200, 113, 50, 255}},
+		{"color blue-amber", mpo.AnaglyphOptions{Colors: mpo.BlueAmber, Method: mpo.ColorAnaglyph}, color.RGBA{20, 140, 50, 255}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := m.ConvertToAnaglyphWithOptions(&tc.opts)
+			if err != nil {
+				t.Fatalf("ConvertToAnaglyphWithOptions failed: %v", err)
+			}
+			if got := color.RGBAModel.Convert(img.At(0, 0)).(color.RGBA); got != tc.want {
+				t.Errorf("anaglyph = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// the default method keeps the output of ConvertToAnaglyph
+	for _, tc := range []struct {
+		name string
+		opts mpo.AnaglyphOptions
+	}{
+		{"red-cyan", mpo.AnaglyphOptions{Colors: mpo.RedCyan}},
+		{"green-red", mpo.AnaglyphOptions{Colors: mpo.GreenRed}},
+		{"amber-blue", mpo.AnaglyphOptions{Colors: mpo.AmberBlue}},
+	} {
+		t.Run("default "+tc.name, func(t *testing.T) {
+			want, err := m.ConvertToAnaglyph(tc.opts.Colors)
+			if err != nil {
+				t.Fatalf("ConvertToAnaglyph failed: %v", err)
+			}
+			got, err := m.ConvertToAnaglyphWithOptions(&tc.opts)
+			if err != nil {
+				t.Fatalf("ConvertToAnaglyphWithOptions failed: %v", err)
+			}
+			assertSameImage(t, 0, got, want)
+		})
+	}
+
+	// Dubois for cyan-red glasses is red-cyan with the frames exchanged
+	want, err := (&mpo.MPO{Image: []image.Image{m.Image[1], m.Image[0]}}).ConvertToAnaglyphWithOptions(&mpo.AnaglyphOptions{Colors: mpo.RedCyan, Method: mpo.DuboisAnaglyph})
+	if err != nil {
+		t.Fatalf("ConvertToAnaglyphWithOptions failed: %v", err)
+	}
+	got, err := m.ConvertToAnaglyphWithOptions(&mpo.AnaglyphOptions{Colors: mpo.CyanRed, Method: mpo.DuboisAnaglyph})
+	if err != nil {
+		t.Fatalf("ConvertToAnaglyphWithOptions failed: %v", err)
+	}
+	assertSameImage(t, 0, got, want)
+
+	// magenta-green is green-magenta with the frames exchanged
+	want, err = (&mpo.MPO{Image: []image.Image{m.Image[1], m.Image[0]}}).ConvertToAnaglyph(mpo.GreenMagenta)
+	if err != nil {
+		t.Fatalf("ConvertToAnaglyph failed: %v", err)
+	}
+	got, err = m.ConvertToAnaglyph(mpo.MagentaGreen)
+	if err != nil {
+		t.Fatalf("ConvertToAnaglyph failed: %v", err)
+	}
+	assertSameImage(t, 0, got, want)
+
+	if _, err := m.ConvertToAnaglyphWithOptions(&mpo.AnaglyphOptions{Colors: mpo.RedGreen, Method: mpo.DuboisAnaglyph}); !errors.Is(err, mpo.ErrUnsupportedAnaglyphMethod) {
+		t.Errorf("Dubois red-green = %v, want ErrUnsupportedAnaglyphMethod", err)
+	}
+	if _, err := m.ConvertToAnaglyphWithOptions(&mpo.AnaglyphOptions{Method: mpo.DuboisAnaglyph + 1}); !errors.Is(err, mpo.ErrUnsupportedAnaglyphMethod) {
+		t.Errorf("unknown method = %v, want ErrUnsupportedAnaglyphMethod", err)
+	}
+}
+
+func TestConvertToAnaglyphWithOptions_Matrix(t *testing.T) {
+	left, right := color.RGBA{200, 100, 50, 255}, color.RGBA{20, 140, 220, 255}
+	m := &mpo.MPO{Image: []image.Image{solid(2, 2, left), solid(2, 2, right)}}
+
+	// a custom matrix overrides the colours and method
+	img, err := m.ConvertToAnaglyphWithOptions(&mpo.AnaglyphOptions{
+		Colors: mpo.AmberBlue,
+		Matrix: &mpo.AnaglyphMatrix{
+			Left:  [3][3]float64{{0, 1, 0}, {0, 0, .5}},
+			Right: [3][3]float64{{}, {}, {2, 0, 1}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ConvertToAnaglyphWithOptions failed: %v", err)
+	}
+	// blue is 2·20 + 220, clamped
+	if got, want := color.RGBAModel.Convert(img.At(0, 0)).(color.RGBA), (color.RGBA{100, 25, 255, 255}); got != want {
+		t.Errorf("anaglyph = %v, want %v", got, want)
+	}
+
+	nan := &mpo.AnaglyphMatrix{}
+	nan.Right[1][2] = math.NaN()
+	if _, err := m.ConvertToAnaglyphWithOptions(&mpo.AnaglyphOptions{Matrix: nan}); err == nil {
+		t.Error("expected error for a NaN weight, got nil")
+	}
+}
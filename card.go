@@ -0,0 +1,225 @@
+package mpo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"math"
+)
+
+// Unit is the unit of the physical dimensions in CardOptions.
+type Unit int
+
+const (
+	// Millimetres measures dimensions in millimetres.
+	Millimetres Unit = iota
+
+	// Inches measures dimensions in inches.
+	Inches
+)
+
+// pixels converts v in unit u to a whole number of pixels at dpi.
+func (u Unit) pixels(v, dpi float64) int {
+	if u == Millimetres {
+		v /= 25.4
+	}
+	return int(math.Round(v * dpi))
+}
+
+// CardOptions describe a printed stereo card, as used by ConvertToCard.
+// Physical dimensions are given in Unit.
+type CardOptions struct {
+	// Width and Height are the size of the card.
+	Width, Height float64
+	Unit          Unit
+
+	// DPI is the print resolution. If zero, 300 is used.
+	DPI float64
+
+	// Margin is the border left around the frames, and Gap the space
+	// between neighbouring frames.
+	Margin, Gap float64
+
+	// Background is the colour of the card, borders and gap. If nil, white
+	// is used.
+	Background color.Color
+
+	// Dots draws an alignment dot centred above each frame, in the colour
+	// DotColor or black if nil, to help the viewer fuse the pair.
+	Dots     bool
+	DotColor color.Color
+
+	// Window sets the stereo window, shifting the frames Window source
+	// pixels apart as by ShiftParallax. Positive values place the scene
+	// further behind the card's window, negative values bring it forward.
+	Window int
+
+	// Stereo arranges, mirrors, aligns and scales the frames as for
+	// ConvertToStereoWithOptions, apart from Width and Height which are
+	// ignored. Only the SideBySide and CrossEyed layouts are supported.
+	Stereo StereoOptions
+}
+
+// HolmesCard is a 7×3½ inch Holmes stereoview card for a parallel viewer.
+var HolmesCard = CardOptions{
+	Width:  7,
+	Height: 3.5,
+	Unit:   Inches,
+	Margin: 0.25,
+	Gap:    0.125,
+	Stereo: StereoOptions{Layout: SideBySide},
+}
+
+// FreeViewPostcard is a 148×100 mm postcard laid out for cross-eyed free
+// viewing.
+var FreeViewPostcard = CardOptions{
+	Width:  148,
+	Height: 100,
+	Unit:   Millimetres,
+	Margin: 6,
+	Gap:    4,
+	Dots:   true,
+	Stereo: StereoOptions{Layout: CrossEyed},
+}
+
+// ConvertToCard lays out the frames of the DefaultPair of an MPO on a
+// printable stereo card as specified by o. The frames are arranged by
+// o.Stereo as ConvertToStereo arranges them, then scaled together to the
+// largest size that fits within the margins, with o.Gap between neighbouring
+// frames. The result has the pixel size of the card at o.DPI;
+// EncodeJPEGWithDPI records the DPI so it prints at the intended size.
+//
+// ErrNoImages is returned if the MPO holds no frames.
+// ErrUnsupportedLayout is returned if the layout, mirror or alignment
+// requested is not supported.
+func (m *MPO) ConvertToCard(o *CardOptions) (image.Image, error) {
+	if o == nil {
+		o = &HolmesCard
+	}
+	if o.Stereo.Layout != SideBySide && o.Stereo.Layout != CrossEyed {
+		return nil, fmt.Errorf("unsupported card layout %d: %w", o.Stereo.Layout, ErrUnsupportedLayout)
+	}
+	if o.Stereo.Mirror < MirrorNone || o.Stereo.Mirror > MirrorRight {
+		return nil, fmt.Errorf("unsupported mirror %d: %w", o.Stereo.Mirror, ErrUnsupportedLayout)
+	}
+	if o.Stereo.Align < AlignTop || o.Stereo.Align > AlignBottom {
+		return nil, fmt.Errorf("unsupported alignment %d: %w", o.Stereo.Align, ErrUnsupportedLayout)
+	}
+	if len(m.Image) == 0 {
+		return nil, ErrNoImages
+	}
+	if o.Width <= 0 || o.Height <= 0 {
+		return nil, fmt.Errorf("invalid card size %gx%g", o.Width, o.Height)
+	}
+
+	dpi := o.DPI
+	if dpi == 0 {
+		dpi = 300
+	}
+
+	shifted, err := m.viewPair().ShiftParallax(o.Window)
+	if err != nil {
+		return nil, err
+	}
+	frames, rects, size := stereoLayout(shifted.Image, &o.Stereo)
+
+	cw, ch := o.Unit.pixels(o.Width, dpi), o.Unit.pixels(o.Height, dpi)
+	margin, gap := o.Unit.pixels(o.Margin, dpi), o.Unit.pixels(o.Gap, dpi)
+
+	n := len(frames)
+	availW := cw - 2*margin - gap*(n-1)
+	availH := ch - 2*margin
+	if availW <= 0 || availH <= 0 {
+		return nil, errors.New("card too small for its margin and gap")
+	}
+	if size.X == 0 || size.Y == 0 {
+		return nil, errors.New("frames have no area")
+	}
+
+	// the stereo layout is scaled as a whole and centred on the card, with
+	// the gap inserted between neighbouring frames
+	scale := min(float64(availW)/float64(size.X), float64(availH)/float64(size.Y))
+	ox := (cw - int(math.Round(float64(size.X)*scale)) - gap*(n-1)) / 2
+	oy := (ch - int(math.Round(float64(size.Y)*scale))) / 2
+	at := func(x, y, i int) image.Point {
+		return image.Pt(ox+int(math.Round(float64(x)*scale))+i*gap, oy+int(math.Round(float64(y)*scale)))
+	}
+
+	bg := o.Background
+	if bg == nil {
+		bg = color.White
+	}
+	img := image.NewRGBA(image.Rect(0, 0, cw, ch))
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+
+	for i, f := range frames {
+		r := rects[i]
+		dst := image.Rectangle{at(r.Min.X, r.Min.Y, i), at(r.Max.X, r.Max.Y, i)}
+		drawScaled(img, dst, f, o.Stereo.interpolator())
+
+		if o.Dots {
+			dc := o.DotColor
+			if dc == nil {
+				dc = color.Black
+			}
+			d := max(Millimetres.pixels(2, dpi), 1)
+			cx := (dst.Min.X + dst.Max.X) / 2
+			drawDot(img, image.Pt(cx, max(dst.Min.Y/2, d/2)), d, dc)
+		}
+	}
+
+	return img, nil
+}
+
+// drawDot draws a filled circle of diameter d centred on c.
+func drawDot(img *image.RGBA, c image.Point, d int, col color.Color) {
+	r := float64(d) / 2
+	for y := c.Y - d; y <= c.Y+d; y++ {
+		for x := c.X - d; x <= c.X+d; x++ {
+			dx, dy := float64(x-c.X)+.5, float64(y-c.Y)+.5
+			if dx*dx+dy*dy <= r*r {
+				img.Set(x, y, col)
+			}
+		}
+	}
+}
+
+// EncodeJPEGWithDPI writes img to w as a JPEG with an APP0/JFIF segment
+// recording dpi as its pixel density, so it prints at its intended size.
+func EncodeJPEGWithDPI(w io.Writer, img image.Image, o *jpeg.Options, dpi float64) error {
+	if dpi <= 0 || dpi > 0xFFFF {
+		return fmt.Errorf("DPI %g out of range (0, %d]", dpi, 0xFFFF)
+	}
+
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, img, o); err != nil {
+		return err
+	}
+	buf := b.Bytes()
+
+	d := uint16(math.Round(dpi))
+	jfif := []byte{
+		0xFF, 0xE0, 0x00, 0x10, // APP0 marker & length
+		'J', 'F', 'I', 'F', 0x00,
+		0x01, 0x02, // version 1.02
+		0x01,                  // density in dots per inch
+		byte(d >> 8), byte(d), // X density
+		byte(d >> 8), byte(d), // Y density
+		0x00, 0x00, // no thumbnail
+	}
+
+	split := 2 + findJFIFEnd(buf[2:])           // replace any existing JFIF segment
+	if _, err := w.Write(buf[:2]); err != nil { // SOI
+		return err
+	}
+	if _, err := w.Write(jfif); err != nil {
+		return err
+	}
+	_, err := w.Write(buf[split:])
+	return err
+}
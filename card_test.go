@@ -0,0 +1,100 @@
+package mpo_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+func TestConvertToCard(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	frame := image.NewRGBA(image.Rect(0, 0, 30, 30))
+	for x := range 30 {
+		for y := range 30 {
+			frame.Set(x, y, red)
+		}
+	}
+	m := &mpo.MPO{Image: []image.Image{frame, frame}}
+
+	o := mpo.HolmesCard
+	o.DPI = 100
+	o.Dots = true
+	card, err := m.ConvertToCard(&o)
+	if err != nil {
+		t.Fatalf("ConvertToCard failed: %v", err)
+	}
+
+	// 7×3½ inches at 100 DPI.
+	if b := card.Bounds(); b.Dx() != 700 || b.Dy() != 350 {
+		t.Fatalf("card is %dx%d, want 700x350", b.Dx(), b.Dy())
+	}
+
+	tests := []struct {
+		name string
+		p    image.Point
+		want color.Color
+	}{
+		{"margin", image.Pt(5, 5), color.White},
+		{"gap", image.Pt(350, 175), color.White},
+		{"left frame", image.Pt(193, 175), red},
+		{"right frame", image.Pt(506, 175), red},
+		{"left dot", image.Pt(193, 12), color.Black},
+		{"right dot", image.Pt(506, 12), color.Black},
+	}
+	for _, tc := range tests {
+		if got, want := color.RGBAModel.Convert(card.At(tc.p.X, tc.p.Y)), color.RGBAModel.Convert(tc.want); got != want {
+			t.Errorf("%s pixel %v = %v, want %v", tc.name, tc.p, got, want)
+		}
+	}
+
+	o.Window = 30
+	if _, err := m.ConvertToCard(&o); err == nil {
+		t.Error("expected error for a stereo window as wide as the frames, got nil")
+	}
+}
+
+func TestConvertToCard_Align(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	m := &mpo.MPO{Image: []image.Image{solid(30, 30, red), solid(30, 15, blue)}}
+
+	o := mpo.HolmesCard
+	o.DPI = 100
+	o.Stereo.Align = mpo.AlignBottom
+	card, err := m.ConvertToCard(&o)
+	if err != nil {
+		t.Fatalf("ConvertToCard failed: %v", err)
+	}
+
+	// The shorter right frame sits at the bottom of the left one.
+	tests := []struct {
+		name string
+		p    image.Point
+		want color.Color
+	}{
+		{"left frame", image.Pt(193, 100), red},
+		{"above right frame", image.Pt(430, 100), color.White},
+		{"right frame", image.Pt(430, 250), blue},
+	}
+	for _, tc := range tests {
+		if got, want := color.RGBAModel.Convert(card.At(tc.p.X, tc.p.Y)), color.RGBAModel.Convert(tc.want); got != want {
+			t.Errorf("%s pixel %v = %v, want %v", tc.name, tc.p, got, want)
+		}
+	}
+}
+
+func TestEncodeJPEGWithDPI(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+
+	var buf bytes.Buffer
+	if err := mpo.EncodeJPEGWithDPI(&buf, img, nil, 300); err != nil {
+		t.Fatalf("EncodeJPEGWithDPI failed: %v", err)
+	}
+
+	want := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00, 0x01, 0x02, 0x01, 0x01, 0x2C, 0x01, 0x2C}
+	if !bytes.HasPrefix(buf.Bytes(), want) {
+		t.Errorf("JPEG header = % X, want % X", buf.Bytes()[:len(want)], want)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"flag"
 	"fmt"
 	"image"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/donatj/mpo"
 
@@ -20,13 +23,36 @@ import (
 var (
 	output  = flag.String("outfile", "output.mpo", "Output filename")
 	quality = flag.Int("quality", 90, "JPEG quality [0-100]")
+	repr    = flag.Int("representative", 0, "Index of the image shown by viewers without MPO support")
+	thumbs  = flag.String("thumbnails", "none", "Large Thumbnail previews of the representative image [none|vga|fullhd|all]")
+	exif    = flag.Bool("exif", false, "Add an Exif segment to every image, as some viewers require")
+	camMake = flag.String("make", "", "Camera make recorded with -exif")
+	model   = flag.String("model", "", "Camera model recorded with -exif")
+	split   = flag.String("split", "none", "Split each image into a stereo pair laid out as [none|stereo|cross-eyed|over-under|half-sbs|half-over-under]")
+	gap     = flag.Int("gap", 0, "Width in pixels of the divider between the frames of -split images")
+	trim    = flag.Bool("trim", false, "Remove a uniform divider and border from -split images")
 )
 
+var splitLayouts = map[string]mpo.StereoLayout{
+	"stereo":          mpo.SideBySide,
+	"cross-eyed":      mpo.CrossEyed,
+	"over-under":      mpo.OverUnder,
+	"half-sbs":        mpo.HalfSideBySide,
+	"half-over-under": mpo.HalfOverUnder,
+}
+
+// stereoDecoders split single image stereo formats into their frames.
+var stereoDecoders = map[string]func(io.Reader) (*mpo.MPO, error){
+	".jps": mpo.DecodeJPS,
+	".pns": mpo.DecodePNS,
+}
+
 func init() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s <imagefile> [<imagefile> ...]\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Convert one or more images to a Multi-Picture Object (MPO) file.\n\n")
-		fmt.Fprintf(os.Stderr, "Supported image formats: JPEG, PNG, GIF, BMP, TIFF, WebP\n\n")
+		fmt.Fprintf(os.Stderr, "Supported image formats: JPEG, PNG, GIF, BMP, TIFF, WebP\n")
+		fmt.Fprintf(os.Stderr, "JPS (.jps) and PNS (.pns) files are split into their left and right images.\n\n")
 		flag.PrintDefaults()
 	}
 
@@ -40,6 +66,16 @@ func init() {
 }
 
 func main() {
+	var so *mpo.SplitOptions
+	if *split != "none" {
+		layout, ok := splitLayouts[*split]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Unknown split: %s\n", *split)
+			os.Exit(2)
+		}
+		so = &mpo.SplitOptions{Layout: layout, Gap: *gap, TrimGap: *trim}
+	}
+
 	images := make([]image.Image, 0, flag.NArg())
 	for _, arg := range flag.Args() {
 		f, err := os.Open(arg)
@@ -49,15 +85,49 @@ func main() {
 		}
 		defer f.Close()
 
+		if decode, ok := stereoDecoders[strings.ToLower(filepath.Ext(arg))]; ok {
+			m, err := decode(f)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error decoding %s: %v\n", arg, err)
+				os.Exit(1)
+			}
+			images = append(images, m.Image...)
+			continue
+		}
+
 		img, _, err := image.Decode(f)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error decoding %s: %v\n", arg, err)
 			os.Exit(1)
 		}
 
+		if so != nil {
+			m, err := mpo.Split(img, so)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error splitting %s: %v\n", arg, err)
+				os.Exit(1)
+			}
+			images = append(images, m.Image...)
+			continue
+		}
+
 		images = append(images, img)
 	}
 
+	var lt mpo.LargeThumbnail
+	switch *thumbs {
+	case "none":
+	case "vga":
+		lt = mpo.ThumbnailVGA
+	case "fullhd":
+		lt = mpo.ThumbnailFullHD
+	case "all":
+		lt = mpo.ThumbnailVGA | mpo.ThumbnailFullHD
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown thumbnails: %s\n", *thumbs)
+		os.Exit(2)
+	}
+
 	if len(images) == 0 {
 		fmt.Fprintln(os.Stderr, "No images to encode")
 		os.Exit(1)
@@ -70,7 +140,16 @@ func main() {
 	}
 	defer f.Close()
 
-	err = mpo.EncodeAll(f, &mpo.MPO{Image: images}, &jpeg.Options{Quality: *quality})
+	opts := &mpo.EncodeOptions{
+		JPEG:            &jpeg.Options{Quality: *quality},
+		Representative:  *repr,
+		LargeThumbnails: lt,
+	}
+	if *exif {
+		opts.Exif = &mpo.ExifOptions{Make: *camMake, Model: *model}
+	}
+
+	err = mpo.EncodeAllWithOptions(f, &mpo.MPO{Image: images}, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error encoding MPO: %v\n", err)
 		os.Exit(1)
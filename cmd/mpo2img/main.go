@@ -1,22 +1,114 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
+	"image/gif"
 	"image/jpeg"
+	"image/png"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/donatj/mpo"
+	xdraw "golang.org/x/image/draw"
 )
 
 var (
-	format = flag.String("format", "stereo", "Output format [stereo|red-cyan|cyan-red|red-green|green-red]")
-	output = flag.String("outfile", "output.jpg", "Output filename")
+	format   = flag.String("format", "stereo", "Output format [stereo|cross-eyed|over-under|half-sbs|half-over-under|card|row-interleaved|column-interleaved|checkerboard|red-cyan|cyan-red|red-green|green-red|anaglyph|amber-blue|blue-amber|magenta-green|green-magenta|disparity|wiggle|jps|pns|lenticular|pitch-test|vr]")
+	mirror   = flag.String("mirror", "none", "Stereo frame to flip horizontally for mirror stereoscopes [none|left|right]")
+	valign   = flag.String("valign", "top", "Alignment of stereo frames of different sizes [top|center|bottom]")
+	left     = flag.Int("left", -1, "Frame used as the left eye, paired with the next unless -right is given; -1 for the default pair")
+	right    = flag.Int("right", -1, "Frame used as the right eye, paired with the previous unless -left is given; -1 for the default pair")
+	allviews = flag.Bool("allviews", false, "Use every frame of multi-frame files for the wiggle and lenticular formats, not just the pair")
+	align    = flag.Bool("align", false, "Correct vertical offset, rotation and scale between the stereo frames")
+	fit      = flag.Bool("fit", false, "Scale stereo frames of different sizes to match instead of aligning them")
+	method   = flag.String("method", "default", "Anaglyph method of the coloured anaglyph formats such as red-cyan [default|true|gray|color|half-color|optimized|dubois]")
+	matrix   = flag.String("matrix", "", "Left then right 3x3 matrix of the anaglyph format as 18 comma-separated weights, row by row")
+	matfile  = flag.String("matrixfile", "", "JSON file of the left and right matrices of the anaglyph format, as {\"left\": [[r, g, b], ...], \"right\": ...}")
+	even     = flag.String("even", "left", "Eye given the even rows, columns or cells of interleaved formats [left|right]")
+	card     = flag.String("card", "holmes", "Card layout for the card format [holmes|postcard]")
+	dpi      = flag.Float64("dpi", 300, "Print resolution of the card, lenticular and pitch-test formats")
+	lpi      = flag.Float64("lpi", 60, "Lenses per inch of the sheet for the lenticular format, or the nominal pitch for pitch-test")
+	reverse  = flag.Bool("reverse", false, "Reverse the order of the views under each lens of the lenticular format")
+	fill     = flag.Bool("fill", false, "Fill unmatched areas of the disparity format from their surroundings")
+	delay    = flag.Duration("delay", 150*time.Millisecond, "Time each frame is shown by the wiggle format")
+	pingpong = flag.Bool("pingpong", false, "Play the wiggle format forwards then backwards")
+	colors   = flag.Int("colors", 256, "Palette size of the wiggle format")
+	dither   = flag.Bool("dither", false, "Dither the wiggle format to its palette")
+	width    = flag.Int("width", 0, "Width in pixels of the output or of each frame, depending on the format, 0 for any")
+	height   = flag.Int("height", 0, "Height in pixels of the output or of each frame, depending on the format, 0 for any")
+	interp   = flag.String("interp", "catmull-rom", "Interpolation used to scale the frames [nearest|approx-bilinear|bilinear|catmull-rom]")
+	viewer   = flag.String("viewer", "cardboard-v2", "Viewer of the vr format [cardboard-v1|cardboard-v2|daydream]")
+	screen   = flag.Float64("screen", 5.5, "Screen diagonal in inches of the phone used with the vr format")
+	fov      = flag.Float64("fov", 60, "Horizontal field of view in degrees of each frame of the vr format")
+	output   = flag.String("outfile", "output.jpg", "Output filename, written as PNG if it ends in .png and JPEG otherwise, GIF for the wiggle format and JPS or PNS for those formats")
+	parallax = flag.Int("parallax", 0, "Pixels to shift the frames apart, positive moves the scene behind the screen")
+	auto     = flag.Bool("auto", false, "Shift the frames so the disparity at -percentile sits at the screen plane, before -parallax")
+	pct      = flag.Float64("percentile", 0, "Disparity percentile placed at the screen plane by -auto, 0 is the nearest object")
 )
 
-func init() {
+var stereoLayouts = map[string]mpo.StereoLayout{
+	"stereo":          mpo.SideBySide,
+	"cross-eyed":      mpo.CrossEyed,
+	"over-under":      mpo.OverUnder,
+	"half-sbs":        mpo.HalfSideBySide,
+	"half-over-under": mpo.HalfOverUnder,
+}
+
+var vrViewers = map[string]mpo.VRViewer{
+	"cardboard-v1": mpo.CardboardV1,
+	"cardboard-v2": mpo.CardboardV2,
+	"daydream":     mpo.DaydreamView,
+}
+
+var interpolators = map[string]xdraw.Interpolator{
+	"nearest":         xdraw.NearestNeighbor,
+	"approx-bilinear": xdraw.ApproxBiLinear,
+	"bilinear":        xdraw.BiLinear,
+	"catmull-rom":     xdraw.CatmullRom,
+}
+
+var cardLayouts = map[string]mpo.CardOptions{
+	"holmes":   mpo.HolmesCard,
+	"postcard": mpo.FreeViewPostcard,
+}
+
+var anaglyphColors = map[string]mpo.ColorType{
+	"red-cyan":      mpo.RedCyan,
+	"cyan-red":      mpo.CyanRed,
+	"red-green":     mpo.RedGreen,
+	"green-red":     mpo.GreenRed,
+	"amber-blue":    mpo.AmberBlue,
+	"blue-amber":    mpo.BlueAmber,
+	"magenta-green": mpo.MagentaGreen,
+	"green-magenta": mpo.GreenMagenta,
+}
+
+var anaglyphMethods = map[string]mpo.AnaglyphMethod{
+	"default":    mpo.DefaultAnaglyph,
+	"true":       mpo.TrueAnaglyph,
+	"gray":       mpo.GrayAnaglyph,
+	"color":      mpo.ColorAnaglyph,
+	"half-color": mpo.HalfColorAnaglyph,
+	"optimized":  mpo.OptimizedAnaglyph,
+	"dubois":     mpo.DuboisAnaglyph,
+}
+
+var interleavePatterns = map[string]mpo.InterleavePattern{
+	"row-interleaved":    mpo.RowInterleaved,
+	"column-interleaved": mpo.ColumnInterleaved,
+	"checkerboard":       mpo.Checkerboard,
+}
+
+// parseFlags parses the command line, exiting unless it names one file.
+func parseFlags() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s <mpofile>\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Convert a Multi-Picture Object (MPO) file to an image.\n\n")
@@ -30,9 +122,37 @@ func init() {
 		flag.Usage()
 		os.Exit(2)
 	}
+
+	if (*matrix != "" || *matfile != "") && *format != "anaglyph" {
+		fmt.Fprintln(os.Stderr, "Error: -matrix and -matrixfile apply only to the anaglyph format.")
+		os.Exit(2)
+	}
+}
+
+// pairFrames returns the frames of an n-frame file to use as the left and
+// right eyes given the -left and -right flags, either of which may be -1.
+// Given only one eye, the other is its neighbour on the same side as in the
+// file, so the depth is not inverted.
+func pairFrames(n, left, right int) (l, r int, err error) {
+	switch {
+	case right < 0:
+		if left+1 >= n {
+			return 0, 0, fmt.Errorf("frame %d is the last of %d, give -right too", left, n)
+		}
+		return left, left + 1, nil
+	case left < 0:
+		if right < 1 {
+			return 0, 0, fmt.Errorf("frame %d is the first, give -left too", right)
+		}
+		return right - 1, right, nil
+	}
+
+	return left, right, nil
 }
 
 func main() {
+	parseFlags()
+
 	r, err := os.Open(flag.Arg(0))
 	if err != nil {
 		log.Fatalf("err on %v %s", err, flag.Arg(0))
@@ -43,40 +163,286 @@ func main() {
 		log.Fatalf("err on %v %s", err, flag.Arg(0))
 	}
 
+	if *left >= 0 || *right >= 0 {
+		l, r, err := pairFrames(len(m.Image), *left, *right)
+		if err != nil {
+			log.Fatal(err)
+		}
+		m, err = m.SelectPair(l, r)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *align {
+		var a mpo.Alignment
+		m, a, err = m.Align()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintln(os.Stderr, "Aligned:", a)
+	}
+
+	shift := *parallax
+	if *auto {
+		d, err := m.EstimateDisparities()
+		if err != nil {
+			log.Fatal(err)
+		}
+		lo, hi := d.Range()
+		px := d.ZeroParallax(*pct)
+		fmt.Fprintf(os.Stderr, "Disparity: %.1f to %.1f px, shifting %d px\n", lo, hi, px)
+		shift += px
+	}
+
+	m, err = m.ShiftParallax(shift)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ip, ok := interpolators[*interp]
+	if !ok {
+		log.Fatal("Unknown interp:", *interp)
+	}
+
+	switch *format {
+	case "stereo", "cross-eyed", "over-under", "half-sbs", "half-over-under", "card", "wiggle", "lenticular", "pitch-test", "vr":
+	default:
+		m, err = m.Resize(*width, *height, ip)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	so := &mpo.StereoOptions{ScaleToFit: *fit, Interpolator: ip}
+	switch *valign {
+	case "top":
+	case "center":
+		so.Align = mpo.AlignCenter
+	case "bottom":
+		so.Align = mpo.AlignBottom
+	default:
+		log.Fatal("Unknown valign:", *valign)
+	}
+	switch *mirror {
+	case "none":
+	case "left":
+		so.Mirror = mpo.MirrorLeft
+	case "right":
+		so.Mirror = mpo.MirrorRight
+	default:
+		log.Fatal("Unknown mirror:", *mirror)
+	}
+
+	isPNG := strings.EqualFold(filepath.Ext(*output), ".png")
+
 	var img image.Image
+	var anim *gif.GIF
 	switch *format {
-	case "stereo":
-		img = m.ConvertToStereo()
-	case "red-cyan":
-		img, err = m.ConvertToAnaglyph(mpo.RedCyan)
+	case "stereo", "cross-eyed", "over-under", "half-sbs", "half-over-under":
+		so.Layout = stereoLayouts[*format]
+		so.Width, so.Height = *width, *height
+		img, err = m.ConvertToStereoWithOptions(so)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "card":
+		co, ok := cardLayouts[*card]
+		if !ok {
+			log.Fatal("Unknown card:", *card)
+		}
+		co.DPI = *dpi
+		co.Stereo.Mirror, co.Stereo.Align = so.Mirror, so.Align
+		co.Stereo.ScaleToFit, co.Stereo.Interpolator = so.ScaleToFit, so.Interpolator
+		img, err = m.ConvertToCard(&co)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "row-interleaved", "column-interleaved", "checkerboard":
+		il := &mpo.InterleaveOptions{Pattern: interleavePatterns[*format]}
+		switch *even {
+		case "left":
+		case "right":
+			il.RightEven = true
+		default:
+			log.Fatal("Unknown even:", *even)
+		}
+		img, err = m.ConvertToInterleaved(il)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "disparity":
+		dm, err := m.ComputeDisparityMap(&mpo.DisparityOptions{FillHoles: *fill})
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "Disparity: %.1f to %.1f px\n", dm.Min, dm.Max)
+		if isPNG {
+			img = dm.Gray16()
+		} else {
+			img = dm.Gray()
+		}
+	case "wiggle":
+		anim, err = m.ConvertToWiggle(&mpo.WiggleOptions{
+			Delay:    *delay,
+			PingPong: *pingpong,
+			Width:    *width,
+			Height:   *height,
+			Colors:   *colors,
+			Dither:   *dither,
+			AllViews: *allviews,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "lenticular":
+		img, err = m.ConvertToLenticular(&mpo.LenticularOptions{
+			LPI:      *lpi,
+			DPI:      *dpi,
+			Width:    *width,
+			Height:   *height,
+			Reverse:  *reverse,
+			AllViews: *allviews,
+		})
 		if err != nil {
 			log.Fatal(err)
 		}
-	case "cyan-red":
-		img, err = m.ConvertToAnaglyph(mpo.CyanRed)
+	case "pitch-test":
+		img, err = mpo.LenticularPitchTest(&mpo.PitchTestOptions{LPI: *lpi, DPI: *dpi})
 		if err != nil {
 			log.Fatal(err)
 		}
-	case "red-green":
-		img, err = m.ConvertToAnaglyph(mpo.RedGreen)
+	case "vr":
+		v, ok := vrViewers[*viewer]
+		if !ok {
+			log.Fatal("Unknown viewer:", *viewer)
+		}
+		img, err = m.ConvertToVR(&mpo.VROptions{
+			Viewer:      v,
+			Width:       *width,
+			Height:      *height,
+			ScreenSize:  *screen,
+			FieldOfView: *fov,
+		})
 		if err != nil {
 			log.Fatal(err)
 		}
-	case "green-red":
-		img, err = m.ConvertToAnaglyph(mpo.GreenRed)
+	case "anaglyph":
+		am, err := anaglyphMatrix()
 		if err != nil {
 			log.Fatal(err)
 		}
+		img, err = m.ConvertToAnaglyphWithOptions(&mpo.AnaglyphOptions{Matrix: am})
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "jps", "pns":
 	default:
-		log.Fatal("Unknown format:", *format)
+		ct, ok := anaglyphColors[*format]
+		if !ok {
+			log.Fatal("Unknown format:", *format)
+		}
+		am, ok := anaglyphMethods[*method]
+		if !ok {
+			log.Fatal("Unknown method:", *method)
+		}
+		img, err = m.ConvertToAnaglyphWithOptions(&mpo.AnaglyphOptions{Colors: ct, Method: am})
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	f, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY, 0666)
+	f, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if err = jpeg.Encode(f, img, nil); err != nil {
+	switch {
+	case *format == "jps":
+		err = mpo.EncodeJPS(f, m, nil)
+	case *format == "pns":
+		err = mpo.EncodePNS(f, m)
+	case anim != nil:
+		err = gif.EncodeAll(f, anim)
+	case isPNG:
+		err = png.Encode(f, img)
+	case *format == "card", *format == "lenticular", *format == "pitch-test":
+		err = mpo.EncodeJPEGWithDPI(f, img, nil, *dpi)
+	default:
+		err = jpeg.Encode(f, img, nil)
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+// anaglyphMatrix returns the matrix given by -matrix or -matrixfile.
+func anaglyphMatrix() (*mpo.AnaglyphMatrix, error) {
+	am := &mpo.AnaglyphMatrix{}
+	switch {
+	case *matrix != "" && *matfile != "":
+		return nil, errors.New("only one of -matrix and -matrixfile may be given")
+	case *matrix != "":
+		w := strings.Split(*matrix, ",")
+		if len(w) != 18 {
+			return nil, fmt.Errorf("-matrix needs 18 weights, got %d", len(w))
+		}
+		for i, v := range w {
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("-matrix: %w", err)
+			}
+			if i < 9 {
+				am.Left[i/3][i%3] = f
+			} else {
+				am.Right[i/3-3][i%3] = f
+			}
+		}
+	case *matfile != "":
+		b, err := os.ReadFile(*matfile)
+		if err != nil {
+			return nil, err
+		}
+		am, err = parseMatrixJSON(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", *matfile, err)
+		}
+	default:
+		return nil, errors.New("the anaglyph format requires -matrix or -matrixfile")
+	}
+
+	return am, nil
+}
+
+// parseMatrixJSON parses the left and right matrices of a -matrixfile, each
+// of which must be exactly 3 rows of 3 weights.
+func parseMatrixJSON(b []byte) (*mpo.AnaglyphMatrix, error) {
+	var f struct {
+		Left, Right [][]float64
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+
+	am := &mpo.AnaglyphMatrix{}
+	for _, m := range []struct {
+		name string
+		rows [][]float64
+		dst  *[3][3]float64
+	}{
+		{"left", f.Left, &am.Left},
+		{"right", f.Right, &am.Right},
+	} {
+		if len(m.rows) != 3 {
+			return nil, fmt.Errorf("%s matrix has %d rows, want 3", m.name, len(m.rows))
+		}
+		for i, row := range m.rows {
+			if len(row) != 3 {
+				return nil, fmt.Errorf("%s matrix row %d has %d weights, want 3", m.name, i, len(row))
+			}
+			copy(m.dst[i][:], row)
+		}
+	}
+
+	return am, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+func TestPairFrames(t *testing.T) {
+	tests := []struct {
+		name        string
+		left, right int
+		wantL       int
+		wantR       int
+		wantErr     bool
+	}{
+		{name: "both", left: 2, right: 0, wantL: 2, wantR: 0},
+		{name: "left only", left: 1, right: -1, wantL: 1, wantR: 2},
+		{name: "first left only", left: 0, right: -1, wantL: 0, wantR: 1},
+		{name: "last left only", left: 2, right: -1, wantErr: true},
+		{name: "right only", left: -1, right: 1, wantL: 0, wantR: 1},
+		{name: "last right only", left: -1, right: 2, wantL: 1, wantR: 2},
+		{name: "first right only", left: -1, right: 0, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l, r, err := pairFrames(3, tc.left, tc.right)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("pairFrames(3, %d, %d) = %d, %d, want error", tc.left, tc.right, l, r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("pairFrames(3, %d, %d) failed: %v", tc.left, tc.right, err)
+			}
+			if l != tc.wantL || r != tc.wantR {
+				t.Errorf("pairFrames(3, %d, %d) = %d, %d, want %d, %d", tc.left, tc.right, l, r, tc.wantL, tc.wantR)
+			}
+			// without both flags, the left eye is never a later frame
+			if (tc.left < 0 || tc.right < 0) && l >= r {
+				t.Errorf("pairFrames(3, %d, %d) puts frame %d on the left of %d", tc.left, tc.right, l, r)
+			}
+		})
+	}
+}
+
+func TestParseMatrixJSON(t *testing.T) {
+	got, err := parseMatrixJSON([]byte(`{"left": [[1, 0, 0], [0, 0, 0], [0, 0, 0]], "right": [[0, 0, 0], [0, 1, 0], [0, 0, 1]]}`))
+	if err != nil {
+		t.Fatalf("parseMatrixJSON failed: %v", err)
+	}
+	want := &mpo.AnaglyphMatrix{
+		Left:  [3][3]float64{{1, 0, 0}, {0, 0, 0}, {0, 0, 0}},
+		Right: [3][3]float64{{0, 0, 0}, {0, 1, 0}, {0, 0, 1}},
+	}
+	if *got != *want {
+		t.Errorf("parseMatrixJSON = %v, want %v", *got, *want)
+	}
+
+	for _, in := range []string{
+		`{"right": [[0, 0, 0], [0, 1, 0], [0, 0, 1]]}`,
+		`{"left": [[1, 0, 0], [0, 0, 0]], "right": [[0, 0, 0], [0, 1, 0], [0, 0, 1]]}`,
+		`{"left": [[1, 0, 0], [0, 0, 0], [0, 0, 0], [0, 0, 0]], "right": [[0, 0, 0], [0, 1, 0], [0, 0, 1]]}`,
+		`{"left": [[1, 0, 0], [0, 0, 0], [0, 0, 0]], "right": [[0, 0], [0, 1, 0], [0, 0, 1]]}`,
+		`{"left": [[1, 0, 0], [0, 0, 0], [0, 0, 0]], "right": [[0, 0, 0, 0], [0, 1, 0], [0, 0, 1]]}`,
+	} {
+		if _, err := parseMatrixJSON([]byte(in)); err == nil {
+			t.Errorf("parseMatrixJSON(%s) succeeded, want error", in)
+		}
+	}
+}
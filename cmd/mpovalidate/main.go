@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/donatj/mpo"
+)
+
+var (
+	asJSON  = flag.Bool("json", false, "Print the results as JSON")
+	minimum = flag.String("level", "info", "Lowest severity reported [info|warning|error]")
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s <mpofile> [<mpofile> ...]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Check Multi-Picture Object (MPO) files against CIPA DC-007.\n")
+		fmt.Fprintf(os.Stderr, "Exits with status 1 if any file has errors.\n\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "Error: At least one MPO file is required.")
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+type result struct {
+	File   string      `json:"file"`
+	Issues []mpo.Issue `json:"issues"`
+}
+
+func main() {
+	var level mpo.Severity
+	switch *minimum {
+	case "info":
+		level = mpo.SeverityInfo
+	case "warning":
+		level = mpo.SeverityWarning
+	case "error":
+		level = mpo.SeverityError
+	default:
+		log.Fatal("Unknown level:", *minimum)
+	}
+
+	results := make([]result, 0, flag.NArg())
+	failed := false
+	for _, arg := range flag.Args() {
+		f, err := os.Open(arg)
+		if err != nil {
+			log.Fatalf("err on %v %s", err, arg)
+		}
+
+		issues, err := mpo.Validate(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("err on %v %s", err, arg)
+		}
+
+		res := result{File: arg, Issues: []mpo.Issue{}}
+		for _, is := range issues {
+			if is.Severity == mpo.SeverityError {
+				failed = true
+			}
+			if is.Severity >= level {
+				res.Issues = append(res.Issues, is)
+			}
+		}
+		results = append(results, res)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		for _, res := range results {
+			if len(res.Issues) == 0 {
+				fmt.Printf("%s: ok\n", res.File)
+			}
+			for _, is := range res.Issues {
+				fmt.Printf("%s: %s\n", res.File, is)
+			}
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
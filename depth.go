@@ -0,0 +1,347 @@
+package mpo
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/bits"
+)
+
+// DisparityOptions are the parameters used by ComputeDisparityMap.
+type DisparityOptions struct {
+	// Width is the width the frames are reduced to before matching, and so
+	// the width of the map. Zero means 640; frames narrower than Width are
+	// matched at full resolution.
+	Width int
+
+	// BlockSize is the side in pixels of the square blocks compared at the
+	// matching resolution. Larger blocks give smoother maps with less
+	// detail. Zero means 9; even sizes are rounded up.
+	BlockSize int
+
+	// MinDisparity and MaxDisparity bound the disparities searched, in
+	// pixels of the frames. If both are zero the range is estimated with
+	// EstimateDisparities.
+	MinDisparity, MaxDisparity int
+
+	// FillHoles replaces pixels that could not be matched, typically areas
+	// hidden from one eye or without texture, with the farther of the
+	// disparities either side of them on the same row.
+	FillHoles bool
+}
+
+// DisparityMap is a dense disparity estimate for the left frame of a stereo
+// pair. Disparities are measured as for Disparities, in pixels of the
+// frames: the smaller, the nearer.
+type DisparityMap struct {
+	// Width and Height are the dimensions of the map, which may be smaller
+	// than the frames.
+	Width, Height int
+
+	// Disparity holds the disparity of each pixel, row by row, or NaN where
+	// none could be determined.
+	Disparity []float32
+
+	// Min and Max are the range of the disparities in the map.
+	Min, Max float64
+}
+
+// At returns the disparity at x, y, or NaN if it is unknown.
+func (d *DisparityMap) At(x, y int) float64 {
+	return float64(d.Disparity[y*d.Width+x])
+}
+
+// Gray returns the map as an 8-bit depth image, with the nearest pixels
+// (disparity Min) white, the farthest (Max) 1 and unknown pixels 0.
+func (d *DisparityMap) Gray() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, d.Width, d.Height))
+	for i, v := range d.Disparity {
+		img.Pix[i] = uint8(d.depth(v, 0xFF))
+	}
+	return img
+}
+
+// Gray16 returns the map as a 16-bit depth image, scaled as by Gray.
+func (d *DisparityMap) Gray16() *image.Gray16 {
+	img := image.NewGray16(image.Rect(0, 0, d.Width, d.Height))
+	for i, v := range d.Disparity {
+		img.SetGray16(i%d.Width, i/d.Width, color.Gray16{Y: uint16(d.depth(v, 0xFFFF))})
+	}
+	return img
+}
+
+// depth maps the disparity v into [1, top], nearest highest, or 0 if v is
+// unknown.
+func (d *DisparityMap) depth(v float32, top float64) float64 {
+	if math.IsNaN(float64(v)) {
+		return 0
+	}
+	if d.Max <= d.Min {
+		return top
+	}
+	return 1 + math.Round((d.Max-float64(v))/(d.Max-d.Min)*(top-1))
+}
+
+const (
+	censusRadius     = 2    // census transform window is 5×5
+	uniquenessMargin = 0.05 // the best cost must beat the others by this much
+)
+
+// ComputeDisparityMap estimates the disparity of every pixel of the left
+// frame of the stereo pair m by matching census transformed blocks against
+// the right frame along the same row. Pixels whose best match is ambiguous
+// or is not confirmed by matching back from the right frame are left
+// unknown unless o.FillHoles is set. A nil o uses the defaults.
+//
+// The frames of m's DefaultPair are used, and should be vertically aligned,
+// as by Align. The errors of ConvertToAnaglyph are returned if m has no pair
+// of frames of the same size, and those of EstimateDisparities if the range
+// is estimated.
+func (m *MPO) ComputeDisparityMap(o *DisparityOptions) (*DisparityMap, error) {
+	if o == nil {
+		o = &DisparityOptions{}
+	}
+	left, right, err := m.stereoPair()
+	if err != nil {
+		return nil, err
+	}
+
+	width := o.Width
+	if width <= 0 {
+		width = 640
+	}
+	block := o.BlockSize
+	if block <= 0 {
+		block = 9
+	}
+	radius := block / 2
+
+	lo, hi := float64(o.MinDisparity), float64(o.MaxDisparity)
+	if lo == 0 && hi == 0 {
+		d, err := m.EstimateDisparities()
+		if err != nil {
+			return nil, err
+		}
+		lo, hi = d.Range()
+		margin := (hi-lo)/10 + 2
+		lo, hi = lo-margin, hi+margin
+	}
+
+	l, factor := toGray(left, width)
+	r, _ := toGray(right, width)
+	dmin := int(math.Floor(lo / factor))
+	dmax := max(int(math.Ceil(hi/factor)), dmin)
+
+	disp := matchBlocks(l, r, dmin, dmax, radius)
+	if o.FillHoles {
+		fillHoles(disp, l.w)
+	}
+
+	dm := &DisparityMap{Width: l.w, Height: l.h, Disparity: disp, Min: math.Inf(1), Max: math.Inf(-1)}
+	for i, v := range disp {
+		if math.IsNaN(float64(v)) {
+			continue
+		}
+		disp[i] = float32(float64(v) * factor)
+		dm.Min = min(dm.Min, float64(disp[i]))
+		dm.Max = max(dm.Max, float64(disp[i]))
+	}
+	if dm.Min > dm.Max {
+		dm.Min, dm.Max = 0, 0
+	}
+
+	return dm, nil
+}
+
+// census returns the census transform of g: for every pixel, a bit per
+// neighbour in the surrounding window set if the neighbour is darker.
+func census(g *grayImage) []uint32 {
+	out := make([]uint32, g.w*g.h)
+	for y := range g.h {
+		for x := range g.w {
+			c := g.at(x, y)
+			var v uint32
+			for dy := -censusRadius; dy <= censusRadius; dy++ {
+				py := max(0, min(y+dy, g.h-1))
+				for dx := -censusRadius; dx <= censusRadius; dx++ {
+					if dx == 0 && dy == 0 {
+						continue
+					}
+					px := max(0, min(x+dx, g.w-1))
+					v <<= 1
+					if g.at(px, py) < c {
+						v |= 1
+					}
+				}
+			}
+			out[y*g.w+x] = v
+		}
+	}
+	return out
+}
+
+// matchBlocks returns the disparity of each pixel of l, from dmin to dmax,
+// minimising the Hamming distance between census transforms summed over
+// blocks of the given radius, or NaN where the match is not reliable.
+func matchBlocks(l, r *grayImage, dmin, dmax, radius int) []float32 {
+	w, h := l.w, l.h
+	cl, cr := census(l), census(r)
+	n := w * h
+
+	const unmatched = math.MaxFloat32
+	bestL := make([]int, n)
+	costL := make([]float32, n)
+	prevL := make([]float32, n) // cost at bestL-1
+	nextL := make([]float32, n) // cost at bestL+1
+	// second is the lowest cost at disparities more than 1 from bestL, and
+	// below the lowest at disparities at least 2 less than the current one,
+	// which second restarts from when bestL moves
+	second := make([]float32, n)
+	below := make([]float32, n)
+	bestR := make([]int, n)
+	costR := make([]float32, n)
+	for i := range n {
+		costL[i], second[i], below[i], costR[i] = unmatched, unmatched, unmatched, unmatched
+		prevL[i], nextL[i] = unmatched, unmatched
+	}
+
+	raw := make([]float32, n)
+	agg := make([]float32, n)
+	last := make([]float32, n)  // costs at d-1
+	older := make([]float32, n) // costs at d-2
+	integral := make([]float32, (w+1)*(h+1))
+	for d := dmin; d <= dmax; d++ {
+		for y := range h {
+			for x := range w {
+				i := y*w + x
+				if xr := x + d; xr >= 0 && xr < w {
+					raw[i] = float32(bits.OnesCount32(cl[i] ^ cr[y*w+xr]))
+				} else {
+					raw[i] = 24 // every census bit differs
+				}
+			}
+		}
+		boxSum(raw, agg, integral, w, h, radius)
+
+		for y := range h {
+			for x := range w {
+				i := y*w + x
+				c := agg[i]
+				if d-2 >= dmin {
+					below[i] = min(below[i], older[i])
+				}
+				if bestL[i] == d-1 && d > dmin {
+					nextL[i] = c
+				}
+				switch {
+				case c < costL[i]:
+					// only disparities less than d have been seen
+					bestL[i], costL[i], nextL[i] = d, c, unmatched
+					second[i] = below[i]
+					prevL[i] = unmatched
+					if d > dmin {
+						prevL[i] = last[i]
+					}
+				case d > bestL[i]+1:
+					second[i] = min(second[i], c)
+				}
+
+				if xr := x + d; xr >= 0 && xr < w && c < costR[y*w+xr] {
+					bestR[y*w+xr], costR[y*w+xr] = d, c
+				}
+			}
+		}
+		agg, last, older = older, agg, last
+	}
+
+	out := make([]float32, n)
+	for y := range h {
+		for x := range w {
+			i := y*w + x
+			d := bestL[i]
+			xr := x + d
+			switch {
+			case costL[i] == unmatched, xr < 0, xr >= w,
+				second[i] != unmatched && costL[i] > second[i]*(1-uniquenessMargin),
+				abs(bestR[y*w+xr]-d) > 1:
+				out[i] = float32(math.NaN())
+			default:
+				out[i] = float32(d) + costMinimum(prevL[i], costL[i], nextL[i])
+			}
+		}
+	}
+
+	return out
+}
+
+// costMinimum returns the offset, in [-0.5, 0.5], of the minimum of the
+// parabola through the costs either side of and at a minimum.
+func costMinimum(prev, at, next float32) float32 {
+	if prev == math.MaxFloat32 || next == math.MaxFloat32 {
+		return 0
+	}
+	d := prev - 2*at + next
+	if d <= 0 {
+		return 0
+	}
+	return max(-0.5, min(0.5, (prev-next)/(2*d)))
+}
+
+// boxSum sets dst to the sums of src over squares of the given radius,
+// clipped to the image, using integral as scratch space.
+func boxSum(src, dst, integral []float32, w, h, radius int) {
+	stride := w + 1
+	for y := range h {
+		var row float32
+		for x := range w {
+			row += src[y*w+x]
+			integral[(y+1)*stride+x+1] = integral[y*stride+x+1] + row
+		}
+	}
+
+	for y := range h {
+		y0, y1 := max(y-radius, 0), min(y+radius+1, h)
+		for x := range w {
+			x0, x1 := max(x-radius, 0), min(x+radius+1, w)
+			sum := integral[y1*stride+x1] - integral[y0*stride+x1] - integral[y1*stride+x0] + integral[y0*stride+x0]
+			dst[y*w+x] = sum / float32((y1-y0)*(x1-x0))
+		}
+	}
+}
+
+// fillHoles replaces the NaN runs of each row of disp with the larger,
+// farther, of the values either side of them.
+func fillHoles(disp []float32, w int) {
+	for row := 0; row < len(disp); row += w {
+		line := disp[row : row+w]
+		for x := 0; x < w; {
+			if !math.IsNaN(float64(line[x])) {
+				x++
+				continue
+			}
+			end := x
+			for end < w && math.IsNaN(float64(line[end])) {
+				end++
+			}
+
+			fill := float32(math.NaN())
+			if x > 0 {
+				fill = line[x-1]
+			}
+			if end < w && (math.IsNaN(float64(fill)) || line[end] > fill) {
+				fill = line[end]
+			}
+			for i := x; i < end; i++ {
+				line[i] = fill
+			}
+			x = end
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,79 @@
+package mpo_test
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+func TestComputeDisparityMap(t *testing.T) {
+	m := layeredPair(640, 480, image.Rect(200, 140, 440, 340), 4, -10)
+
+	dm, err := m.ComputeDisparityMap(&mpo.DisparityOptions{Width: 320, FillHoles: true})
+	if err != nil {
+		t.Fatalf("ComputeDisparityMap failed: %v", err)
+	}
+	if dm.Width != 320 || dm.Height != 240 {
+		t.Fatalf("map is %dx%d, want 320x240", dm.Width, dm.Height)
+	}
+
+	// map pixels are 2 frame pixels wide
+	if d := dm.At(160, 120); math.Abs(d+10) > 1 {
+		t.Errorf("disparity inside the near rectangle = %.2f, want -10", d)
+	}
+	if d := dm.At(50, 50); math.Abs(d-4) > 1 {
+		t.Errorf("disparity of the background = %.2f, want 4", d)
+	}
+	if math.Abs(dm.Min+10) > 1 || math.Abs(dm.Max-4) > 1 {
+		t.Errorf("range = %.2f, %.2f, want -10, 4", dm.Min, dm.Max)
+	}
+
+	var unknown, wrong int
+	for y := range dm.Height {
+		for x := range dm.Width {
+			d := dm.At(x, y)
+			if math.IsNaN(d) {
+				unknown++
+				continue
+			}
+			want := 4.0
+			if image.Pt(2*x, 2*y).In(image.Rect(200, 140, 440, 340)) {
+				want = -10
+			}
+			if math.Abs(d-want) > 2 {
+				wrong++
+			}
+		}
+	}
+	if unknown > 0 {
+		t.Errorf("%d unknown pixels after filling holes", unknown)
+	}
+	if total := dm.Width * dm.Height; wrong > total/20 {
+		t.Errorf("%d of %d pixels off by more than 2", wrong, total)
+	}
+
+	gray := dm.Gray()
+	if near, far := gray.GrayAt(160, 120).Y, gray.GrayAt(50, 50).Y; near < 220 || far > 35 {
+		t.Errorf("depth image near = %d, far = %d, want near white and far dark", near, far)
+	}
+	if near := dm.Gray16().Gray16At(160, 120).Y; near < 0xDC00 {
+		t.Errorf("16-bit depth image near = %d, want near white", near)
+	}
+}
+
+func TestComputeDisparityMapRange(t *testing.T) {
+	m := layeredPair(320, 240, image.Rect(100, 70, 220, 170), 2, -6)
+
+	dm, err := m.ComputeDisparityMap(&mpo.DisparityOptions{MinDisparity: -8, MaxDisparity: 4, BlockSize: 7})
+	if err != nil {
+		t.Fatalf("ComputeDisparityMap failed: %v", err)
+	}
+	if d := dm.At(160, 120); math.Abs(d+6) > 1 {
+		t.Errorf("disparity inside the near rectangle = %.2f, want -6", d)
+	}
+	if dm.Min < -8 || dm.Max > 4 {
+		t.Errorf("range %.2f, %.2f outside the searched -8, 4", dm.Min, dm.Max)
+	}
+}
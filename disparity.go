@@ -0,0 +1,95 @@
+package mpo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Disparities are the horizontal offsets, in pixels, of points matched
+// between the left and right frames of a stereo pair, measured as the
+// position in the right frame less the position in the left and sorted in
+// increasing order. Negative disparities are in front of the screen plane
+// and positive ones behind it; the smallest is the nearest object.
+type Disparities []float64
+
+const disparityWidth = 320 // width the frames are reduced to for matching
+
+// EstimateDisparities matches well textured points spread over the left
+// frame of m's DefaultPair against the right frame and returns their
+// disparities. The frames should be vertically aligned, as by Align.
+//
+// ErrAlignmentFailed is returned if no point could be matched, and the
+// errors of ConvertToAnaglyph if m has no pair of frames of the same size.
+func (m *MPO) EstimateDisparities() (Disparities, error) {
+	left, right, err := m.stereoPair()
+	if err != nil {
+		return nil, err
+	}
+
+	l, factor := toGray(left, disparityWidth)
+	r, _ := toGray(right, disparityWidth)
+
+	ms := matchConsistent(l, r, 20, 14, l.w/4, max(l.h/40, 2))
+	if len(ms) == 0 {
+		return nil, fmt.Errorf("no disparities found: %w", ErrAlignmentFailed)
+	}
+
+	d := make(Disparities, len(ms))
+	for i, mt := range ms {
+		d[i] = mt.dx * factor
+	}
+	sort.Float64s(d)
+
+	return d, nil
+}
+
+// Range returns the smallest and largest disparity.
+func (d Disparities) Range() (lo, hi float64) {
+	if len(d) == 0 {
+		return 0, 0
+	}
+	return d[0], d[len(d)-1]
+}
+
+// Percentile returns the disparity below which p percent of the others
+// lie, interpolating between neighbours. p is clamped to [0, 100].
+func (d Disparities) Percentile(p float64) float64 {
+	if len(d) == 0 {
+		return 0
+	}
+
+	pos := max(0, min(p, 100)) / 100 * float64(len(d)-1)
+	i := int(pos)
+	if i == len(d)-1 {
+		return d[i]
+	}
+	return d[i] + (d[i+1]-d[i])*(pos-float64(i))
+}
+
+// ZeroParallax returns the ShiftParallax offset that places the disparity
+// at percentile p at the screen plane. A p of 0 puts the nearest object on
+// the screen and everything else behind it; a small p such as 2 ignores
+// stray mismatches.
+func (d Disparities) ZeroParallax(p float64) int {
+	return -int(math.Round(d.Percentile(p)))
+}
+
+// AutoParallax estimates the disparities of m's DefaultPair and returns a
+// copy shifted with ShiftParallax so that the disparity at percentile p is
+// at the screen plane, along with the offset applied. See
+// Disparities.ZeroParallax.
+func (m *MPO) AutoParallax(p float64) (*MPO, int, error) {
+	d, err := m.EstimateDisparities()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	px := d.ZeroParallax(p)
+	out, err := m.ShiftParallax(px)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return out, px, nil
+}
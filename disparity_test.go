@@ -0,0 +1,87 @@
+package mpo_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+// layeredPair renders a textured background with disparity bg and, in front
+// of it, a textured rectangle at fgRect in the left frame with disparity fg.
+func layeredPair(w, h int, fgRect image.Rectangle, bg, fg int) *mpo.MPO {
+	back, front := texture(2), texture(3)
+	scene := func(x, y, d int) (uint8, bool) {
+		if image.Pt(x-d, y).In(fgRect) {
+			return uint8(front(float64(x-d), float64(y))), true
+		}
+		return 0, false
+	}
+
+	left := image.NewGray(image.Rect(0, 0, w, h))
+	right := image.NewGray(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			v, ok := scene(x, y, 0)
+			if !ok {
+				v = uint8(back(float64(x), float64(y)))
+			}
+			left.SetGray(x, y, color.Gray{v})
+
+			v, ok = scene(x, y, fg)
+			if !ok {
+				v = uint8(back(float64(x-bg), float64(y)))
+			}
+			right.SetGray(x, y, color.Gray{v})
+		}
+	}
+	return &mpo.MPO{Image: []image.Image{left, right}}
+}
+
+func TestEstimateDisparities(t *testing.T) {
+	m := layeredPair(640, 480, image.Rect(200, 140, 440, 340), 4, -10)
+
+	d, err := m.EstimateDisparities()
+	if err != nil {
+		t.Fatalf("EstimateDisparities failed: %v", err)
+	}
+
+	lo, hi := d.Range()
+	if math.Abs(lo+10) > 1 || math.Abs(hi-4) > 1 {
+		t.Errorf("Range = %.2f, %.2f, want -10, 4", lo, hi)
+	}
+	if px := d.ZeroParallax(0); px != 10 {
+		t.Errorf("ZeroParallax(0) = %d, want 10", px)
+	}
+	if px := d.ZeroParallax(100); px != -4 {
+		t.Errorf("ZeroParallax(100) = %d, want -4", px)
+	}
+	if med := d.Percentile(50); math.Abs(med-4) > 1 {
+		t.Errorf("median disparity = %.2f, want the background's 4", med)
+	}
+}
+
+func TestAutoParallax(t *testing.T) {
+	m := layeredPair(640, 480, image.Rect(200, 140, 440, 340), 4, -10)
+
+	out, px, err := m.AutoParallax(0)
+	if err != nil {
+		t.Fatalf("AutoParallax failed: %v", err)
+	}
+	if px != 10 {
+		t.Errorf("offset = %d, want 10", px)
+	}
+	if dx := out.Image[0].Bounds().Dx(); dx != 630 {
+		t.Errorf("shifted width = %d, want 630", dx)
+	}
+
+	d, err := out.EstimateDisparities()
+	if err != nil {
+		t.Fatalf("EstimateDisparities of shifted pair failed: %v", err)
+	}
+	if lo, _ := d.Range(); math.Abs(lo) > 1 {
+		t.Errorf("nearest disparity after shift = %.2f, want 0", lo)
+	}
+}
@@ -0,0 +1,180 @@
+package mpo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"time"
+)
+
+// ExifOptions configures the minimal APP1/Exif segment EncodeAllWithOptions
+// writes into every frame. The segment records the frame's pixel dimensions,
+// DateTime and the optional camera make and model, and carries no thumbnail.
+type ExifOptions struct {
+	// DateTime is recorded as DateTime and DateTimeOriginal. If zero, the
+	// time of encoding is used.
+	DateTime time.Time
+
+	// Make and Model name the camera. Each is omitted if empty. Together
+	// they must fit the 64 KiB APP1 segment, or encoding fails with an error
+	// wrapping ErrTooLarge.
+	Make  string
+	Model string
+}
+
+// Exif tags and field types, see CIPA DC‑008 (Exif 2.3) §4.6.
+const (
+	tagMake                    = 0x010F
+	tagModel                   = 0x0110
+	tagOrientation             = 0x0112
+	tagXResolution             = 0x011A
+	tagYResolution             = 0x011B
+	tagResolutionUnit          = 0x0128
+	tagDateTime                = 0x0132
+	tagYCbCrPositioning        = 0x0213
+	tagExifIFDPointer          = 0x8769
+	tagExifVersion             = 0x9000
+	tagDateTimeOriginal        = 0x9003
+	tagComponentsConfiguration = 0x9101
+	tagFlashpixVersion         = 0xA000
+	tagColorSpace              = 0xA001
+	tagPixelXDimension         = 0xA002
+	tagPixelYDimension         = 0xA003
+
+	typeASCII    = 2
+	typeSHORT    = 3
+	typeRATIONAL = 5
+)
+
+// ifdField is a single field to be written to an IFD.
+type ifdField struct {
+	tag, typ uint16
+	count    uint32
+	data     []byte // little‑endian value, inline if 4 bytes or fewer
+}
+
+// buildExifSegment constructs an APP1/Exif segment for a frame with bounds b:
+// IFD0 with the image description tags, pointing to an Exif IFD with the
+// version and pixel dimensions. An error wrapping ErrTooLarge is returned if
+// the make and model do not fit the segment's 16-bit length field.
+func buildExifSegment(o *ExifOptions, b image.Rectangle) ([]byte, error) {
+	t := o.DateTime
+	if t.IsZero() {
+		t = time.Now()
+	}
+	dateTime := asciiField(t.Format("2006:01:02 15:04:05"))
+
+	var ifd0 []ifdField
+	if o.Make != "" {
+		ifd0 = append(ifd0, ifdField{tagMake, typeASCII, 0, asciiField(o.Make)})
+	}
+	if o.Model != "" {
+		ifd0 = append(ifd0, ifdField{tagModel, typeASCII, 0, asciiField(o.Model)})
+	}
+	ifd0 = append(ifd0,
+		ifdField{tagOrientation, typeSHORT, 1, le16(1)},        // top-left
+		ifdField{tagXResolution, typeRATIONAL, 1, le32(72, 1)}, // 72/1
+		ifdField{tagYResolution, typeRATIONAL, 1, le32(72, 1)}, // 72/1
+		ifdField{tagResolutionUnit, typeSHORT, 1, le16(2)},     // inches
+		ifdField{tagDateTime, typeASCII, 0, dateTime},
+		ifdField{tagYCbCrPositioning, typeSHORT, 1, le16(1)},      // centered
+		ifdField{tagExifIFDPointer, typeLONG, 1, make([]byte, 4)}, // filled in below
+	)
+
+	exifIFD := []ifdField{
+		{tagExifVersion, typeUNDEFINED, 4, []byte("0230")},
+		{tagDateTimeOriginal, typeASCII, 0, dateTime},
+		{tagComponentsConfiguration, typeUNDEFINED, 4, []byte{1, 2, 3, 0}}, // Y Cb Cr
+		{tagFlashpixVersion, typeUNDEFINED, 4, []byte("0100")},
+		{tagColorSpace, typeSHORT, 1, le16(1)}, // sRGB
+		{tagPixelXDimension, typeLONG, 1, le32(uint32(b.Dx()))},
+		{tagPixelYDimension, typeLONG, 1, le32(uint32(b.Dy()))},
+	}
+
+	exifOffset := tiffHeaderSize + ifdSize(ifd0)
+	binary.LittleEndian.PutUint32(ifd0[len(ifd0)-1].data, uint32(exifOffset))
+
+	bb := new(bytes.Buffer)
+	// APP1 marker & length placeholder
+	bb.Write([]byte{0xFF, 0xE1, 0x00, 0x00})
+	// "Exif\0\0"
+	bb.Write([]byte{'E', 'x', 'i', 'f', 0x00, 0x00})
+
+	// TIFF header (little‑endian)
+	bb.Write([]byte("II"))
+	binary.Write(bb, binary.LittleEndian, uint16(0x002A))
+	binary.Write(bb, binary.LittleEndian, uint32(tiffHeaderSize)) // IFD0 after header
+
+	writeIFD(bb, tiffHeaderSize, ifd0)
+	writeIFD(bb, uint32(exifOffset), exifIFD)
+
+	if l := bb.Len() - 2; l > maxSegmentLength {
+		return nil, fmt.Errorf("APP1/Exif segment is %d bytes, limit is %d: %w", l, maxSegmentLength, ErrTooLarge)
+	}
+
+	return finishSegment(bb.Bytes()), nil
+}
+
+// ifdSize returns the size of an IFD with fields, including the values that
+// do not fit inline.
+func ifdSize(fields []ifdField) int {
+	n := 2 + len(fields)*ifdEntrySize + 4
+	for _, f := range fields {
+		if len(f.data) > 4 {
+			n += len(f.data) + len(f.data)%2
+		}
+	}
+	return n
+}
+
+// writeIFD writes fields, which must be sorted by tag, as an IFD starting at TIFF offset
+// off, followed by the values that do not fit inline. The next‑IFD offset is
+// always 0.
+func writeIFD(b *bytes.Buffer, off uint32, fields []ifdField) {
+	binary.Write(b, binary.LittleEndian, uint16(len(fields)))
+
+	dataOff := off + 2 + uint32(len(fields))*ifdEntrySize + 4
+	var data []byte
+	for _, f := range fields {
+		count := f.count
+		if count == 0 {
+			count = uint32(len(f.data)) // ASCII includes the NUL
+		}
+		binary.Write(b, binary.LittleEndian, f.tag)
+		binary.Write(b, binary.LittleEndian, f.typ)
+		binary.Write(b, binary.LittleEndian, count)
+
+		if len(f.data) <= 4 {
+			var v [4]byte
+			copy(v[:], f.data)
+			b.Write(v[:])
+			continue
+		}
+		binary.Write(b, binary.LittleEndian, dataOff+uint32(len(data)))
+		data = append(data, f.data...)
+		if len(f.data)%2 != 0 { // values start on a word boundary
+			data = append(data, 0)
+		}
+	}
+
+	// next‑IFD offset = 0
+	binary.Write(b, binary.LittleEndian, uint32(0))
+	b.Write(data)
+}
+
+func asciiField(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+func le16(v uint16) []byte {
+	return binary.LittleEndian.AppendUint16(nil, v)
+}
+
+func le32(vs ...uint32) []byte {
+	var b []byte
+	for _, v := range vs {
+		b = binary.LittleEndian.AppendUint32(b, v)
+	}
+	return b
+}
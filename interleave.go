@@ -0,0 +1,84 @@
+package mpo
+
+import (
+	"fmt"
+	"image"
+)
+
+// InterleavePattern selects how ConvertToInterleaved mixes the two frames.
+type InterleavePattern int
+
+const (
+	// RowInterleaved alternates rows between the frames, as used by passive
+	// polarized 3D monitors.
+	RowInterleaved InterleavePattern = iota
+
+	// ColumnInterleaved alternates columns between the frames, as used by
+	// some DLP projectors and autostereoscopic panels.
+	ColumnInterleaved
+
+	// Checkerboard alternates pixels between the frames in both directions,
+	// as used by DLP 3D projectors and TVs.
+	Checkerboard
+)
+
+// InterleaveOptions are the parameters used by ConvertToInterleaved.
+type InterleaveOptions struct {
+	Pattern InterleavePattern
+
+	// RightEven gives the right frame the even rows, columns or checkerboard
+	// cells, counting from 0. By default the left frame gets them.
+	RightEven bool
+}
+
+// ConvertToInterleaved converts an MPO to a single image with the rows,
+// columns or checkerboard cells taken alternately from the left and right
+// frames, as specified by o. A nil o interleaves rows with the left frame on
+// the even rows. With more than 2 frames, the DefaultPair is used.
+//
+// ErrInconsistentBounds is returned if the images within the MPO are not the same size.
+// ErrInvalidImageCount is returned if the MPO has fewer than 2 images.
+// ErrUnsupportedLayout is returned if the pattern requested is not supported.
+func (m *MPO) ConvertToInterleaved(o *InterleaveOptions) (image.Image, error) {
+	if o == nil {
+		o = &InterleaveOptions{}
+	}
+	if o.Pattern < RowInterleaved || o.Pattern > Checkerboard {
+		return nil, fmt.Errorf("unsupported interleave pattern %d: %w", o.Pattern, ErrUnsupportedLayout)
+	}
+
+	left, right, err := m.stereoPair()
+	if err != nil {
+		return nil, err
+	}
+
+	even, odd := left, right
+	if o.RightEven {
+		even, odd = right, left
+	}
+
+	b := left.Bounds()
+	img := image.NewRGBA(b)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var n int
+			switch o.Pattern {
+			case RowInterleaved:
+				n = y - b.Min.Y
+			case ColumnInterleaved:
+				n = x - b.Min.X
+			case Checkerboard:
+				n = x - b.Min.X + y - b.Min.Y
+			}
+
+			src := even
+			if n%2 != 0 {
+				src = odd
+			}
+			img.Set(x, y, src.At(x, y))
+		}
+	}
+
+	return img, nil
+}
@@ -0,0 +1,58 @@
+package mpo_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+func TestConvertToInterleaved(t *testing.T) {
+	l := color.RGBA{255, 0, 0, 255}
+	r := color.RGBA{0, 0, 255, 255}
+
+	left := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	right := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for x := range 2 {
+		for y := range 2 {
+			left.Set(x, y, l)
+			right.Set(x, y, r)
+		}
+	}
+	m := &mpo.MPO{Image: []image.Image{left, right}}
+
+	tests := []struct {
+		name string
+		opts mpo.InterleaveOptions
+		want [2][2]color.RGBA // [y][x]
+	}{
+		{"rows", mpo.InterleaveOptions{Pattern: mpo.RowInterleaved}, [2][2]color.RGBA{{l, l}, {r, r}}},
+		{"rows right even", mpo.InterleaveOptions{Pattern: mpo.RowInterleaved, RightEven: true}, [2][2]color.RGBA{{r, r}, {l, l}}},
+		{"columns", mpo.InterleaveOptions{Pattern: mpo.ColumnInterleaved}, [2][2]color.RGBA{{l, r}, {l, r}}},
+		{"checkerboard", mpo.InterleaveOptions{Pattern: mpo.Checkerboard}, [2][2]color.RGBA{{l, r}, {r, l}}},
+		{"checkerboard right even", mpo.InterleaveOptions{Pattern: mpo.Checkerboard, RightEven: true}, [2][2]color.RGBA{{r, l}, {l, r}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := m.ConvertToInterleaved(&tc.opts)
+			if err != nil {
+				t.Fatalf("ConvertToInterleaved failed: %v", err)
+			}
+			for y := range 2 {
+				for x := range 2 {
+					if got := color.RGBAModel.Convert(img.At(x, y)); got != tc.want[y][x] {
+						t.Errorf("pixel %d,%d = %v, want %v", x, y, got, tc.want[y][x])
+					}
+				}
+			}
+		})
+	}
+
+	single := &mpo.MPO{Image: []image.Image{left}}
+	if _, err := single.ConvertToInterleaved(nil); !errors.Is(err, mpo.ErrInvalidImageCount) {
+		t.Errorf("single frame error = %v, want ErrInvalidImageCount", err)
+	}
+}
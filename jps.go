@@ -0,0 +1,203 @@
+package mpo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+)
+
+// JPS stereoscopic descriptor fields, stored big-endian in the APP3/_JPSJPS_
+// segment.
+const (
+	jpsMediaStereo = 0x00000001
+
+	jpsHalfHeight     = 0x00000100
+	jpsHalfWidth      = 0x00000200
+	jpsLeftFieldFirst = 0x00000400
+
+	jpsLayoutMask        = 0x00FF0000
+	jpsLayoutInterleaved = 0x00010000
+	jpsLayoutSideBySide  = 0x00020000
+	jpsLayoutOverUnder   = 0x00030000
+	jpsLayoutAnaglyph    = 0x00040000
+
+	jpsSeparationShift = 24 // pixels between the frames, in the top byte
+
+	mpojpgAPP3 = 0xE3
+)
+
+var jpsIdentifier = []byte("_JPSJPS_")
+
+// JPSOptions are the parameters used by EncodeJPS.
+type JPSOptions struct {
+	// Layout arranges the frames. JPS viewers expect CrossEyed, with the
+	// right eye's frame on the left, but SideBySide, OverUnder and their
+	// half size variants are recorded in the descriptor too.
+	Layout StereoLayout
+
+	// JPEG is used to encode the image. If nil, a quality of 90 is used.
+	JPEG *jpeg.Options
+}
+
+// EncodeJPS writes the DefaultPair of m to w as a JPS (JPEG Stereo) file: a
+// single JPEG with the frames arranged as o.Layout and an APP3 stereoscopic
+// descriptor recording the arrangement. A nil o writes a full size
+// cross-eyed JPS.
+//
+// ErrInvalidImageCount is returned if m has fewer than 2 frames and
+// ErrUnsupportedLayout for layouts JPS cannot describe.
+func EncodeJPS(w io.Writer, m *MPO, o *JPSOptions) error {
+	if o == nil {
+		o = &JPSOptions{Layout: CrossEyed}
+	}
+	q := o.JPEG
+	if q == nil {
+		q = &jpeg.Options{Quality: 90}
+	}
+
+	desc := uint32(jpsMediaStereo)
+	switch o.Layout {
+	case CrossEyed:
+		desc |= jpsLayoutSideBySide
+	case SideBySide:
+		desc |= jpsLayoutSideBySide | jpsLeftFieldFirst
+	case HalfSideBySide:
+		desc |= jpsLayoutSideBySide | jpsLeftFieldFirst | jpsHalfWidth
+	case OverUnder:
+		desc |= jpsLayoutOverUnder | jpsLeftFieldFirst
+	case HalfOverUnder:
+		desc |= jpsLayoutOverUnder | jpsLeftFieldFirst | jpsHalfHeight
+	default:
+		return fmt.Errorf("unsupported JPS layout %d: %w", o.Layout, ErrUnsupportedLayout)
+	}
+
+	if len(m.Image) < 2 {
+		return ErrInvalidImageCount
+	}
+	pair, err := m.SelectPair(m.DefaultPair())
+	if err != nil {
+		return err
+	}
+	img, err := pair.ConvertToStereoWithOptions(&StereoOptions{Layout: o.Layout})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, q); err != nil {
+		return err
+	}
+
+	return writeWithSegments(w, buf.Bytes(), nil, buildJPSSegment(desc))
+}
+
+// buildJPSSegment returns the APP3/_JPSJPS_ segment holding the stereoscopic
+// descriptor desc.
+func buildJPSSegment(desc uint32) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{mpojpgMKR, mpojpgAPP3})
+	binary.Write(&b, binary.BigEndian, uint16(2+len(jpsIdentifier)+2+4))
+	b.Write(jpsIdentifier)
+	binary.Write(&b, binary.BigEndian, uint16(4)) // descriptor length
+	binary.Write(&b, binary.BigEndian, desc)
+	return b.Bytes()
+}
+
+// DecodeJPS reads a JPS (JPEG Stereo) file from r and splits it into an MPO
+// of its left and right frames, as described by its APP3 stereoscopic
+// descriptor. A JPEG without a descriptor is taken to be a full size
+// cross-eyed pair, and a descriptor marking the image as monoscopic gives a
+// single frame. The separation the descriptor records between the frames is
+// removed, and half size frames are scaled back to their full size.
+//
+// ErrUnsupportedLayout is returned for anaglyph JPS files.
+func DecodeJPS(r io.Reader) (*MPO, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	desc := uint32(jpsMediaStereo | jpsLayoutSideBySide)
+	if segs, err := readSegments(bytes.NewReader(data), 0); err == nil {
+		for _, s := range segs {
+			if s.marker == mpojpgAPP3 && bytes.HasPrefix(s.data, jpsIdentifier) && len(s.data) >= len(jpsIdentifier)+6 {
+				desc = binary.BigEndian.Uint32(s.data[len(jpsIdentifier)+2:])
+				break
+			}
+		}
+	}
+
+	if desc&0xFF != jpsMediaStereo {
+		return &MPO{Image: []image.Image{img}}, nil
+	}
+
+	left, right, err := splitJPS(img, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MPO{Image: []image.Image{left, right}}, nil
+}
+
+// splitJPS returns the left and right frames of img, arranged as described
+// by the JPS stereoscopic descriptor desc.
+func splitJPS(img image.Image, desc uint32) (left, right image.Image, err error) {
+	var layout StereoLayout
+	switch desc & jpsLayoutMask {
+	case jpsLayoutSideBySide:
+		layout = SideBySide
+		if desc&jpsHalfWidth != 0 {
+			layout = HalfSideBySide
+		}
+	case jpsLayoutOverUnder:
+		layout = OverUnder
+		if desc&jpsHalfHeight != 0 {
+			layout = HalfOverUnder
+		}
+	case jpsLayoutInterleaved:
+		even, odd := splitRows(img)
+		b := even.Bounds()
+		left, right = scale(even, b.Dx(), 2*b.Dy()), scale(odd, b.Dx(), 2*b.Dy())
+		if desc&jpsLeftFieldFirst == 0 {
+			left, right = right, left
+		}
+		return left, right, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported JPS layout %#x: %w", desc&jpsLayoutMask, ErrUnsupportedLayout)
+	}
+
+	m, err := Split(img, &SplitOptions{Layout: layout, Gap: int(desc >> jpsSeparationShift)})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	left, right = m.Image[0], m.Image[1]
+	if desc&jpsLeftFieldFirst == 0 {
+		left, right = right, left
+	}
+	return left, right, nil
+}
+
+// splitRows returns the even and odd rows of img as separate images.
+func splitRows(img image.Image) (even, odd *image.RGBA) {
+	b := img.Bounds()
+	even = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()/2))
+	odd = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()/2))
+	for y := range 2 * (b.Dy() / 2) {
+		dst := even
+		if y%2 == 1 {
+			dst = odd
+		}
+		for x := range b.Dx() {
+			dst.Set(x, y/2, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return even, odd
+}
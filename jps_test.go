@@ -0,0 +1,162 @@
+package mpo_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+func TestEncodeJPS(t *testing.T) {
+	red, green := color.RGBA{220, 0, 0, 255}, color.RGBA{0, 220, 0, 255}
+	m := &mpo.MPO{Image: []image.Image{solid(32, 16, red), solid(32, 16, green)}}
+
+	var buf bytes.Buffer
+	if err := mpo.EncodeJPS(&buf, m, nil); err != nil {
+		t.Fatalf("EncodeJPS failed: %v", err)
+	}
+
+	// stereoscopic, side-by-side, right frame first
+	desc := append([]byte("_JPSJPS_"), 0, 4, 0, 2, 0, 1)
+	if !bytes.Contains(buf.Bytes(), desc) {
+		t.Error("APP3 stereoscopic descriptor not found")
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("jpeg.Decode failed: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 16 {
+		t.Fatalf("JPS image is %v, want 64x16", b)
+	}
+	if _, g, _, _ := img.At(8, 8).RGBA(); g>>8 < 180 {
+		t.Errorf("left half = %v, want the right (green) frame", img.At(8, 8))
+	}
+}
+
+func TestDecodeJPS(t *testing.T) {
+	red, green := color.RGBA{220, 0, 0, 255}, color.RGBA{0, 220, 0, 255}
+	m := &mpo.MPO{Image: []image.Image{solid(32, 16, red), solid(32, 16, green)}}
+
+	layouts := []struct {
+		name   string
+		layout mpo.StereoLayout
+	}{
+		{"cross-eyed", mpo.CrossEyed},
+		{"side-by-side", mpo.SideBySide},
+		{"over-under", mpo.OverUnder},
+		{"half-sbs", mpo.HalfSideBySide},
+		{"half-over-under", mpo.HalfOverUnder},
+	}
+
+	for _, tc := range layouts {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := mpo.EncodeJPS(&buf, m, &mpo.JPSOptions{Layout: tc.layout}); err != nil {
+				t.Fatalf("EncodeJPS failed: %v", err)
+			}
+
+			decoded, err := mpo.DecodeJPS(&buf)
+			if err != nil {
+				t.Fatalf("DecodeJPS failed: %v", err)
+			}
+			if len(decoded.Image) != 2 {
+				t.Fatalf("got %d frames, want 2", len(decoded.Image))
+			}
+			for i, want := range []color.RGBA{red, green} {
+				img := decoded.Image[i]
+				if b := img.Bounds(); b.Dx() != 32 || b.Dy() != 16 {
+					t.Errorf("frame %d is %v, want 32x16", i, b)
+				}
+				c := img.At(img.Bounds().Min.X+16, img.Bounds().Min.Y+8)
+				r, g, _, _ := c.RGBA()
+				if absDiff(uint8(r>>8), want.R) > 40 || absDiff(uint8(g>>8), want.G) > 40 {
+					t.Errorf("frame %d = %v, want approximately %v", i, c, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeJPSSeparation(t *testing.T) {
+	// a side-by-side pair with 4 black pixels between the frames
+	sbs := image.NewRGBA(image.Rect(0, 0, 36, 8))
+	for y := range 8 {
+		for x := range 36 {
+			c := color.RGBA{0, 0, 0, 255}
+			switch {
+			case x < 16:
+				c = color.RGBA{220, 0, 0, 255}
+			case x >= 20:
+				c = color.RGBA{0, 0, 220, 255}
+			}
+			sbs.Set(x, y, c)
+		}
+	}
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, sbs, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	// APP3/_JPSJPS_ descriptor: stereo, left field first, side-by-side,
+	// separation 4
+	app3 := []byte{0xFF, 0xE3, 0x00, 0x10}
+	app3 = append(app3, "_JPSJPS_"...)
+	app3 = append(app3, 0x00, 0x04, 0x04, 0x02, 0x04, 0x01)
+	data := append(append(append([]byte{}, jpg.Bytes()[:2]...), app3...), jpg.Bytes()[2:]...)
+
+	m, err := mpo.DecodeJPS(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DecodeJPS failed: %v", err)
+	}
+	if len(m.Image) != 2 {
+		t.Fatalf("got %d frames, want 2", len(m.Image))
+	}
+	for i, img := range m.Image {
+		if b := img.Bounds(); b.Dx() != 16 || b.Dy() != 8 {
+			t.Errorf("frame %d is %v, want 16x8", i, b)
+		}
+	}
+
+	// the separation is not part of either frame
+	l, r := m.Image[0], m.Image[1]
+	if red, _, _, _ := l.At(l.Bounds().Max.X-1, 4).RGBA(); red>>8 < 150 {
+		t.Errorf("last column of left frame = %v, want red", l.At(l.Bounds().Max.X-1, 4))
+	}
+	if _, _, blue, _ := r.At(r.Bounds().Min.X, 4).RGBA(); blue>>8 < 150 {
+		t.Errorf("first column of right frame = %v, want blue", r.At(r.Bounds().Min.X, 4))
+	}
+}
+
+func TestDecodeJPSWithoutDescriptor(t *testing.T) {
+	// a plain side-by-side JPEG is read as cross-eyed
+	sbs := image.NewRGBA(image.Rect(0, 0, 32, 8))
+	for y := range 8 {
+		for x := range 32 {
+			c := color.RGBA{220, 0, 0, 255}
+			if x >= 16 {
+				c = color.RGBA{0, 0, 220, 255}
+			}
+			sbs.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, sbs, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := mpo.DecodeJPS(&buf)
+	if err != nil {
+		t.Fatalf("DecodeJPS failed: %v", err)
+	}
+	if len(m.Image) != 2 {
+		t.Fatalf("got %d frames, want 2", len(m.Image))
+	}
+	left := m.Image[0]
+	if _, _, b, _ := left.At(left.Bounds().Min.X+8, 4).RGBA(); b>>8 < 180 {
+		t.Errorf("left frame = %v, want the blue right half", left.At(left.Bounds().Min.X+8, 4))
+	}
+}
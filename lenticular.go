@@ -0,0 +1,201 @@
+package mpo
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// LenticularOptions are the parameters used by ConvertToLenticular.
+type LenticularOptions struct {
+	// LPI is the pitch of the lenticular sheet in lenses per inch. It
+	// need not be a whole number, and should be the pitch measured with a
+	// LenticularPitchTest print, which often differs from the nominal one.
+	LPI float64
+
+	// DPI is the print resolution. Zero means 300.
+	DPI float64
+
+	// Width and Height are the size of the result in pixels. If only one
+	// is given the other follows the frames' aspect ratio; zero for both
+	// keeps the frames' size.
+	Width, Height int
+
+	// Reverse reverses the order of the views under each lens. By default
+	// the first frame, the leftmost viewpoint, is placed on the right of
+	// each lens, as the lens flips the strips beneath it.
+	Reverse bool
+
+	// AllViews interlaces every frame of a multi-frame MPO, in viewpoint
+	// order, rather than its DefaultPair.
+	AllViews bool
+}
+
+// ConvertToLenticular interlaces the DefaultPair of m, or with o.AllViews
+// all its frames in viewpoint order, into strips for printing behind a
+// lenticular sheet. Under each lens, of o.DPI/o.LPI pixels, every view gets
+// an equal share of the width; with a fractional pitch, a column straddling
+// two views' strips blends them by the share of the column each covers.
+//
+// ErrInvalidImageCount is returned if m has fewer than 2 frames and
+// ErrInconsistentBounds if they are not all the same size.
+func (m *MPO) ConvertToLenticular(o *LenticularOptions) (image.Image, error) {
+	if o == nil || o.LPI <= 0 {
+		return nil, errors.New("lenticular pitch must be positive")
+	}
+	if len(m.Image) < 2 {
+		return nil, ErrInvalidImageCount
+	}
+	if !o.AllViews {
+		m = m.viewPair()
+	}
+	dpi := o.DPI
+	if dpi == 0 {
+		dpi = 300
+	}
+	pitch := dpi / o.LPI // pixels per lens
+	n := len(m.Image)
+	if pitch < float64(n) {
+		return nil, fmt.Errorf("%g DPI gives %.2f pixels per lens at %g LPI, fewer than the %d views", dpi, pitch, o.LPI, n)
+	}
+
+	size := m.Image[0].Bounds().Size()
+	for _, img := range m.Image[1:] {
+		if img.Bounds().Size() != size {
+			return nil, ErrInconsistentBounds
+		}
+	}
+
+	w, h := o.Width, o.Height
+	switch {
+	case w <= 0 && h <= 0:
+		w, h = size.X, size.Y
+	case w <= 0:
+		w = max(size.X*h/size.Y, 1)
+	case h <= 0:
+		h = max(size.Y*w/size.X, 1)
+	}
+
+	views := make([]*image.RGBA, n)
+	for i, img := range m.Image {
+		if w == size.X && h == size.Y {
+			views[i] = image.NewRGBA(image.Rect(0, 0, w, h))
+			draw.Draw(views[i], views[i].Bounds(), img, img.Bounds().Min, draw.Src)
+		} else {
+			views[i] = scale(img, w, h)
+		}
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	view := func(u float64) *image.RGBA {
+		v := int(math.Mod(u, float64(n)))
+		if !o.Reverse {
+			v = n - 1 - v
+		}
+		return views[v]
+	}
+	for x := range w {
+		// the column spans u0 to u1 in units of one view's strip, which
+		// is at least a pixel wide, so it covers at most two views
+		u0, u1 := float64(x)*float64(n)/pitch, float64(x+1)*float64(n)/pitch
+		a, b := view(u0), view(u1)
+		wa := (min(u1, math.Floor(u0)+1) - u0) / (u1 - u0)
+
+		for y := range h {
+			i := out.PixOffset(x, y)
+			for c := range 4 {
+				out.Pix[i+c] = uint8(float64(a.Pix[i+c])*wa + float64(b.Pix[i+c])*(1-wa) + 0.5)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// PitchTestOptions are the parameters used by LenticularPitchTest.
+type PitchTestOptions struct {
+	// LPI is the nominal pitch of the lenticular sheet, at the centre of
+	// the pitches tested.
+	LPI float64
+
+	// DPI is the print resolution. Zero means 300.
+	DPI float64
+
+	// Spread is how far either side of LPI the pitches tested reach, and
+	// Step the difference between them. Zero means 0.5 and 0.1.
+	Spread, Step float64
+
+	// Width is the width of the pattern in inches. Zero means 4.
+	Width float64
+}
+
+// LenticularPitchTest returns a printable pattern for finding the exact
+// pitch of a lenticular sheet on a given printer. It has a band of black
+// lines a quarter of a lens wide for each pitch tested, one line per lens,
+// labelled with the pitch.
+// Seen through the sheet with the lenses parallel to the lines, the band
+// whose pitch matches turns a single uniform shade, and that pitch is the
+// one to give ConvertToLenticular.
+func LenticularPitchTest(o *PitchTestOptions) (*image.RGBA, error) {
+	if o == nil || o.LPI <= 0 {
+		return nil, errors.New("lenticular pitch must be positive")
+	}
+	dpi := o.DPI
+	if dpi == 0 {
+		dpi = 300
+	}
+	spread, step := o.Spread, o.Step
+	if spread <= 0 {
+		spread = 0.5
+	}
+	if step <= 0 {
+		step = 0.1
+	}
+	width := o.Width
+	if width <= 0 {
+		width = 4
+	}
+
+	bands := int(math.Round(2*spread/step)) + 1
+	bandH := max(int(dpi/4), 16)
+	label := basicfont.Face7x13.Advance * 8
+	w := int(width * dpi)
+	if w <= label {
+		return nil, fmt.Errorf("%g inch pitch test too narrow for its labels", width)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, bands*bandH))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	d := &font.Drawer{Dst: img, Src: image.Black, Face: basicfont.Face7x13}
+	for i := range bands {
+		lpi := o.LPI - spread + float64(i)*step
+		pitch := dpi / lpi
+		y0 := i * bandH
+		y1 := y0 + bandH - max(bandH/8, 2) // white space between bands
+
+		d.Dot = fixed.P(4, y0+bandH/2+4)
+		d.DrawString(fmt.Sprintf("%.2f", lpi))
+
+		// lines cover the first quarter of each lens, anti-aliased by the
+		// share of each column they cover
+		covered := func(t float64) float64 {
+			lenses := t / pitch
+			return (math.Floor(lenses)*0.25 + min(lenses-math.Floor(lenses), 0.25)) * pitch
+		}
+		for x := label; x < w; x++ {
+			t := float64(x - label)
+			shade := uint8(255*(1-(covered(t+1)-covered(t))) + 0.5)
+			draw.Draw(img, image.Rect(x, y0, x+1, y1), &image.Uniform{color.Gray{shade}}, image.Point{}, draw.Src)
+		}
+	}
+
+	return img, nil
+}
@@ -0,0 +1,102 @@
+package mpo_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+func TestConvertToLenticular(t *testing.T) {
+	red, green, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}, color.RGBA{0, 0, 255, 255}
+	m := &mpo.MPO{Image: []image.Image{solid(60, 10, red), solid(60, 10, green), solid(60, 10, blue)}}
+
+	// 3 pixels per lens, one per view, the first view on the right
+	img, err := m.ConvertToLenticular(&mpo.LenticularOptions{LPI: 100, DPI: 300, AllViews: true})
+	if err != nil {
+		t.Fatalf("ConvertToLenticular failed: %v", err)
+	}
+	for x, want := range []color.RGBA{blue, green, red, blue, green, red} {
+		if got := img.At(x, 5); got != want {
+			t.Errorf("column %d = %v, want %v", x, got, want)
+		}
+	}
+
+	img, err = m.ConvertToLenticular(&mpo.LenticularOptions{LPI: 100, DPI: 300, Reverse: true, AllViews: true})
+	if err != nil {
+		t.Fatalf("ConvertToLenticular failed: %v", err)
+	}
+	for x, want := range []color.RGBA{red, green, blue} {
+		if got := img.At(x, 5); got != want {
+			t.Errorf("reversed column %d = %v, want %v", x, got, want)
+		}
+	}
+
+	// a fractional pitch of 3.33 pixels still shares the width evenly
+	img, err = m.ConvertToLenticular(&mpo.LenticularOptions{LPI: 90, DPI: 300, Width: 120, AllViews: true})
+	if err != nil {
+		t.Fatalf("ConvertToLenticular failed: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 120 || b.Dy() != 20 {
+		t.Fatalf("lenticular image is %v, want 120x20", b)
+	}
+	var sum [3]int
+	for x := range 120 {
+		r, g, b, _ := img.At(x, 10).RGBA()
+		sum[0] += int(r >> 8)
+		sum[1] += int(g >> 8)
+		sum[2] += int(b >> 8)
+	}
+	for i, s := range sum {
+		if cols := float64(s) / 255; cols < 39.5 || cols > 40.5 {
+			t.Errorf("channel %d covers %.2f of 120 columns, want 40", i, cols)
+		}
+	}
+
+	if _, err := m.ConvertToLenticular(&mpo.LenticularOptions{LPI: 150, DPI: 300, AllViews: true}); err == nil {
+		t.Error("expected error for fewer pixels per lens than views, got nil")
+	}
+
+	// without AllViews only the default pair, the first two views, is used
+	img, err = m.ConvertToLenticular(&mpo.LenticularOptions{LPI: 150, DPI: 300})
+	if err != nil {
+		t.Fatalf("ConvertToLenticular failed: %v", err)
+	}
+	for x, want := range []color.RGBA{green, red} {
+		if got := img.At(x, 5); got != want {
+			t.Errorf("default pair column %d = %v, want %v", x, got, want)
+		}
+	}
+}
+
+func TestLenticularPitchTest(t *testing.T) {
+	img, err := mpo.LenticularPitchTest(&mpo.PitchTestOptions{LPI: 60, DPI: 300, Width: 2})
+	if err != nil {
+		t.Fatalf("LenticularPitchTest failed: %v", err)
+	}
+
+	// 59.5 to 60.5 LPI in steps of 0.1, each band 75 pixels high
+	if b := img.Bounds(); b.Dx() != 600 || b.Dy() != 11*75 {
+		t.Fatalf("pitch test is %v, want 600x825", b)
+	}
+
+	// each band has a line per lens: count line starts across the band
+	lines := func(y int) int {
+		n, prev := 0, false
+		for x := 100; x < 600; x++ {
+			black := img.RGBAAt(x, y).R < 128
+			if black && !prev {
+				n++
+			}
+			prev = black
+		}
+		return n
+	}
+	if n := lines(30); n < 98 || n > 100 {
+		t.Errorf("59.5 LPI band has %d lines over 500 pixels, want about 99", n)
+	}
+	if n := lines(10*75 + 30); n < 100 || n > 102 {
+		t.Errorf("60.5 LPI band has %d lines over 500 pixels, want about 101", n)
+	}
+}
@@ -0,0 +1,276 @@
+package mpo
+
+import (
+	"image"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// grayImage is a single channel float image used for matching frames.
+type grayImage struct {
+	w, h int
+	pix  []float32
+
+	// sum and sq are integral images of pix and its square, with a leading
+	// row and column of zeros, so patch statistics take constant time.
+	sum, sq []float64
+}
+
+func (g *grayImage) at(x, y int) float32 {
+	return g.pix[y*g.w+x]
+}
+
+// toGray converts img to luminance, scaled down so it is at most maxWidth
+// pixels wide. factor is the ratio of img's width to the result's.
+func toGray(img image.Image, maxWidth int) (g *grayImage, factor float64) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	factor = 1
+	if maxWidth > 0 && w > maxWidth {
+		factor = float64(w) / float64(maxWidth)
+		w, h = maxWidth, max(int(math.Round(float64(h)/factor)), 1)
+	}
+
+	// scaled to RGBA, which x/image/draw supports for any source image
+	small := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.ApproxBiLinear.Scale(small, small.Bounds(), img, b, xdraw.Src, nil)
+
+	g = &grayImage{w: w, h: h, pix: make([]float32, w*h)}
+	for i := range g.pix {
+		p := small.Pix[4*i : 4*i+3]
+		g.pix[i] = .299*float32(p[0]) + .587*float32(p[1]) + .114*float32(p[2])
+	}
+	g.integrate()
+
+	return g, factor
+}
+
+// integrate computes the integral images of g.
+func (g *grayImage) integrate() {
+	stride := g.w + 1
+	g.sum = make([]float64, stride*(g.h+1))
+	g.sq = make([]float64, stride*(g.h+1))
+	for y := range g.h {
+		var rs, rq float64
+		for x := range g.w {
+			v := float64(g.pix[y*g.w+x])
+			rs += v
+			rq += v * v
+			i := (y+1)*stride + x + 1
+			g.sum[i] = g.sum[i-stride] + rs
+			g.sq[i] = g.sq[i-stride] + rq
+		}
+	}
+}
+
+// match is a correspondence between a point in the left frame and the
+// right, in the coordinates of the grayImages matched.
+type match struct {
+	x, y   float64 // position in the left frame
+	dx, dy float64 // displacement to the right frame
+	score  float64 // zero-mean normalised cross-correlation, 1 is perfect
+}
+
+const (
+	matchRadius   = 6   // half size of the matched patches
+	matchMinScore = 0.8 // lowest correlation accepted as a match
+)
+
+// featurePoints returns up to one well textured point per cell of a
+// cols×rows grid over g, at least margin pixels from the edges, chosen by
+// the smaller eigenvalue of the local structure tensor so the points can be
+// located in both directions.
+func featurePoints(g *grayImage, cols, rows, margin int) []image.Point {
+	margin = max(margin, matchRadius+1)
+	if g.w <= 2*margin || g.h <= 2*margin {
+		return nil
+	}
+
+	cw := float64(g.w-2*margin) / float64(cols)
+	ch := float64(g.h-2*margin) / float64(rows)
+
+	var pts []image.Point
+	for r := range rows {
+		for c := range cols {
+			x0 := margin + int(float64(c)*cw)
+			y0 := margin + int(float64(r)*ch)
+			x1 := margin + int(float64(c+1)*cw)
+			y1 := margin + int(float64(r+1)*ch)
+
+			best, bestScore := image.Point{}, 0.0
+			for y := y0; y < y1; y += 2 {
+				for x := x0; x < x1; x += 2 {
+					if s := cornerness(g, x, y); s > bestScore {
+						best, bestScore = image.Pt(x, y), s
+					}
+				}
+			}
+			if bestScore > 20 { // reject flat areas
+				pts = append(pts, best)
+			}
+		}
+	}
+
+	return pts
+}
+
+// cornerness returns the smaller eigenvalue of the structure tensor of the
+// patch around x, y, averaged per pixel.
+func cornerness(g *grayImage, x, y int) float64 {
+	var sxx, syy, sxy float64
+	for py := y - matchRadius; py <= y+matchRadius; py++ {
+		for px := x - matchRadius; px <= x+matchRadius; px++ {
+			ix := float64(g.at(px+1, py) - g.at(px-1, py))
+			iy := float64(g.at(px, py+1) - g.at(px, py-1))
+			sxx += ix * ix
+			syy += iy * iy
+			sxy += ix * iy
+		}
+	}
+
+	n := float64((2*matchRadius + 1) * (2*matchRadius + 1))
+	sxx, syy, sxy = sxx/n, syy/n, sxy/n
+	tr, det := sxx+syy, sxx*syy-sxy*sxy
+
+	return tr/2 - math.Sqrt(max(tr*tr/4-det, 0))
+}
+
+// findMatch searches r for the patch of l around x, y, with displacements of
+// up to maxDX horizontally and maxDY vertically, and refines the best to
+// sub-pixel precision. ok is false if no displacement correlates well.
+func findMatch(l, r *grayImage, x, y, maxDX, maxDY int) (m match, ok bool) {
+	mean, norm := patchStats(l, x, y)
+	if norm == 0 {
+		return match{}, false
+	}
+
+	dxs := 2*maxDX + 1
+	scores := make([]float64, dxs*(2*maxDY+1))
+	for i := range scores {
+		scores[i] = -1
+	}
+
+	bestDX, bestDY, best := 0, 0, -1.0
+	for dy := -maxDY; dy <= maxDY; dy++ {
+		ry := y + dy
+		if ry < matchRadius || ry >= r.h-matchRadius {
+			continue
+		}
+		for dx := -maxDX; dx <= maxDX; dx++ {
+			rx := x + dx
+			if rx < matchRadius || rx >= r.w-matchRadius {
+				continue
+			}
+
+			s := zncc(l, r, x, y, rx, ry, mean, norm)
+			scores[(dy+maxDY)*dxs+dx+maxDX] = s
+			if s > best {
+				bestDX, bestDY, best = dx, dy, s
+			}
+		}
+	}
+	if best < matchMinScore {
+		return match{}, false
+	}
+
+	score := func(dx, dy int) float64 {
+		if dx < -maxDX || dx > maxDX || dy < -maxDY || dy > maxDY {
+			return -1
+		}
+		return scores[(dy+maxDY)*dxs+dx+maxDX]
+	}
+
+	return match{
+		x:     float64(x),
+		y:     float64(y),
+		dx:    float64(bestDX) + subPixel(score(bestDX-1, bestDY), best, score(bestDX+1, bestDY)),
+		dy:    float64(bestDY) + subPixel(score(bestDX, bestDY-1), best, score(bestDX, bestDY+1)),
+		score: best,
+	}, true
+}
+
+// subPixel returns the offset, in [-0.5, 0.5], of the peak of the parabola
+// through the scores either side of and at a maximum.
+func subPixel(prev, at, next float64) float64 {
+	if prev < 0 || next < 0 {
+		return 0
+	}
+	d := prev - 2*at + next
+	if d >= 0 {
+		return 0
+	}
+	return max(-0.5, min(0.5, (prev-next)/(2*d)))
+}
+
+// patchStats returns the mean of the patch around x, y in g and the
+// root of the sum of squared deviations from it.
+func patchStats(g *grayImage, x, y int) (mean, norm float64) {
+	stride := g.w + 1
+	x0, y0 := x-matchRadius, y-matchRadius
+	x1, y1 := x+matchRadius+1, y+matchRadius+1
+	box := func(t []float64) float64 {
+		return t[y1*stride+x1] - t[y0*stride+x1] - t[y1*stride+x0] + t[y0*stride+x0]
+	}
+	sum, sq := box(g.sum), box(g.sq)
+
+	n := float64((2*matchRadius + 1) * (2*matchRadius + 1))
+	mean = sum / n
+
+	return mean, math.Sqrt(max(sq-sum*mean, 0))
+}
+
+// zncc returns the zero-mean normalised cross-correlation of the patches
+// around lx, ly in l and rx, ry in r, given the left patch's statistics.
+func zncc(l, r *grayImage, lx, ly, rx, ry int, lMean, lNorm float64) float64 {
+	rMean, rNorm := patchStats(r, rx, ry)
+	if rNorm == 0 {
+		return -1
+	}
+
+	var sum float64
+	for dy := -matchRadius; dy <= matchRadius; dy++ {
+		lrow := (ly+dy)*l.w + lx
+		rrow := (ry+dy)*r.w + rx
+		for dx := -matchRadius; dx <= matchRadius; dx++ {
+			sum += (float64(l.pix[lrow+dx]) - lMean) * (float64(r.pix[rrow+dx]) - rMean)
+		}
+	}
+
+	return sum / (lNorm * rNorm)
+}
+
+// matchFeatures matches well textured points spread over l against r.
+func matchFeatures(l, r *grayImage, maxDX, maxDY int) []match {
+	var ms []match
+	for _, p := range featurePoints(l, 16, 10, maxDY/2) {
+		if m, ok := findMatch(l, r, p.X, p.Y, maxDX, maxDY); ok {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+// matchConsistent matches well textured points spread over l against r,
+// keeping only those that match back to where they started.
+func matchConsistent(l, r *grayImage, cols, rows, maxDX, maxDY int) []match {
+	var ms []match
+	for _, p := range featurePoints(l, cols, rows, maxDY/2) {
+		m, ok := findMatch(l, r, p.X, p.Y, maxDX, maxDY)
+		if !ok {
+			continue
+		}
+
+		rx, ry := int(math.Round(m.x+m.dx)), int(math.Round(m.y+m.dy))
+		if rx < matchRadius || rx >= r.w-matchRadius || ry < matchRadius || ry >= r.h-matchRadius {
+			continue
+		}
+		back, ok := findMatch(r, l, rx, ry, maxDX, maxDY)
+		if !ok || math.Abs(float64(rx)+back.dx-m.x) > 1 || math.Abs(float64(ry)+back.dy-m.y) > 1 {
+			continue
+		}
+
+		ms = append(ms, m)
+	}
+	return ms
+}
@@ -0,0 +1,373 @@
+package mpo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MPF tags, field types and layout sizes, see CIPA DC‑X007 §5.2.
+const (
+	tagMPFVersion      = 0xB000
+	tagNumImages       = 0xB001
+	tagMPImageList     = 0xB002
+	tagMPIndividualNum = 0xB101
+	tagBaseViewpoint   = 0xB204
+	typeUNDEFINED      = 7
+	typeLONG           = 4
+	tiffHeaderSize     = 8
+
+	mpfNumTags     = 3
+	mpfAttrNumTags = 3
+	mpEntrySize    = 16
+	ifdEntrySize   = 12
+
+	// APP2 marker & length, "MPF\0", TIFF header … next‑IFD offset
+	mpfIndexHeaderSize = 4 + 4 + tiffHeaderSize + 2 + mpfNumTags*ifdEntrySize + 4
+	mpfAttrIFDSize     = 2 + mpfAttrNumTags*ifdEntrySize + 4
+	mpfAttrSegmentSize = 4 + 4 + tiffHeaderSize + mpfAttrIFDSize
+)
+
+const (
+	flagDependentParent = 0x80000000
+	flagDependentChild  = 0x40000000
+	flagRepresentative  = 0x20000000
+
+	mpTypeMask             = 0x00FFFFFF
+	mpTypeLargeThumbVGA    = 0x00010001 // Large Thumbnail (VGA equivalent)
+	mpTypeLargeThumbFullHD = 0x00010002 // Large Thumbnail (Full-HD equivalent)
+	mpTypePanorama         = 0x00020001 // Multi-Frame Image (Panorama)
+	mpTypeDisparity        = 0x00020002 // Multi-Frame Image (Disparity)
+	mpTypeMultiAngle       = 0x00020003 // Multi-Frame Image (Multi-Angle)
+	mpTypeBaseline         = 0x00030000 // Baseline MP primary image
+)
+
+// mpEntry is a single 16‑byte entry of the MP Image List.
+type mpEntry struct {
+	attr   uint32
+	size   uint32
+	offset uint32
+	dep1   uint16
+	dep2   uint16
+}
+
+// isLargeThumbnail reports whether e describes a Large Thumbnail image.
+func isLargeThumbnail(e mpEntry) bool {
+	t := e.attr & mpTypeMask
+	return t == mpTypeLargeThumbVGA || t == mpTypeLargeThumbFullHD
+}
+
+// mpfIndexSegmentSize returns the size of the first image's APP2/MPF segment
+// for numImg entries, including the marker.
+func mpfIndexSegmentSize(numImg int) int {
+	return mpfIndexHeaderSize + numImg*mpEntrySize + mpfAttrIFDSize
+}
+
+// buildMPFSegment constructs the APP2/MPF segment of the first image: the MP
+// Index IFD and its entries, followed by the first image's MP Attribute IFD.
+func buildMPFSegment(entries []mpEntry, individualNum, baseViewpoint uint32) []byte {
+	numImg := uint32(len(entries))
+
+	b := new(bytes.Buffer)
+	writeMPFHeader(b)
+
+	// IFD entry count
+	binary.Write(b, binary.LittleEndian, uint16(mpfNumTags))
+
+	// ── tag 0xb000 – MPFVersion ("0100") inline ――――――――――――――――――――――――――――――
+	writeMPFVersion(b)
+
+	// ── tag 0xb001 – NumberOfImages ―――――――――――――――――――――――――――――――――――――
+	binary.Write(b, binary.LittleEndian, uint16(tagNumImages))
+	binary.Write(b, binary.LittleEndian, uint16(typeLONG))
+	binary.Write(b, binary.LittleEndian, uint32(1))
+	binary.Write(b, binary.LittleEndian, numImg)
+
+	// ── tag 0xb002 – MPImageList (offset to 16‑byte entries) ――――――――――――――――
+	entryOffset := uint32(tiffHeaderSize + 2 + mpfNumTags*ifdEntrySize + 4)
+	binary.Write(b, binary.LittleEndian, uint16(tagMPImageList))
+	binary.Write(b, binary.LittleEndian, uint16(typeUNDEFINED))
+	binary.Write(b, binary.LittleEndian, numImg*mpEntrySize)
+	binary.Write(b, binary.LittleEndian, entryOffset)
+
+	// next‑IFD offset – the MP Attribute IFD follows the entries
+	binary.Write(b, binary.LittleEndian, entryOffset+numImg*mpEntrySize)
+
+	// ── MP Entry array ―――――――――――――――――――――――――――――――――――――――――――――――――――
+	for _, e := range entries {
+		binary.Write(b, binary.LittleEndian, e.attr)
+		binary.Write(b, binary.LittleEndian, e.size)
+		binary.Write(b, binary.LittleEndian, e.offset)
+		binary.Write(b, binary.LittleEndian, e.dep1) // Dep‑1
+		binary.Write(b, binary.LittleEndian, e.dep2) // Dep‑2
+	}
+
+	writeAttrIFD(b, individualNum, baseViewpoint)
+
+	return finishSegment(b.Bytes())
+}
+
+// buildAttrSegment constructs the APP2/MPF segment of every image after the
+// first, holding only its MP Attribute IFD.
+func buildAttrSegment(individualNum, baseViewpoint uint32) []byte {
+	b := new(bytes.Buffer)
+	writeMPFHeader(b)
+	writeAttrIFD(b, individualNum, baseViewpoint)
+
+	return finishSegment(b.Bytes())
+}
+
+// writeMPFHeader writes the APP2 marker, a length placeholder, the "MPF\0"
+// identifier and a little‑endian TIFF header pointing directly after itself.
+func writeMPFHeader(b *bytes.Buffer) {
+	// APP2 marker & length placeholder
+	b.Write([]byte{0xFF, 0xE2, 0x00, 0x00})
+	// "MPF\0"
+	b.Write([]byte{'M', 'P', 'F', 0x00})
+
+	// TIFF header (little‑endian)
+	b.Write([]byte("II"))
+	binary.Write(b, binary.LittleEndian, uint16(0x002A))
+	binary.Write(b, binary.LittleEndian, uint32(tiffHeaderSize)) // first IFD after header
+}
+
+func writeMPFVersion(b *bytes.Buffer) {
+	binary.Write(b, binary.LittleEndian, uint16(tagMPFVersion))
+	binary.Write(b, binary.LittleEndian, uint16(typeUNDEFINED))
+	binary.Write(b, binary.LittleEndian, uint32(4))
+	b.Write([]byte{'0', '1', '0', '0'})
+}
+
+// writeAttrIFD writes an MP Attribute IFD recording the image's MP
+// Individual Image Number and the number of the base viewpoint image.
+func writeAttrIFD(b *bytes.Buffer, individualNum, baseViewpoint uint32) {
+	binary.Write(b, binary.LittleEndian, uint16(mpfAttrNumTags))
+
+	writeMPFVersion(b)
+
+	// ── tag 0xb101 – MPIndividualNum ――――――――――――――――――――――――――――――――――――
+	binary.Write(b, binary.LittleEndian, uint16(tagMPIndividualNum))
+	binary.Write(b, binary.LittleEndian, uint16(typeLONG))
+	binary.Write(b, binary.LittleEndian, uint32(1))
+	binary.Write(b, binary.LittleEndian, individualNum)
+
+	// ── tag 0xb204 – BaseViewpointNum ――――――――――――――――――――――――――――――――――――
+	binary.Write(b, binary.LittleEndian, uint16(tagBaseViewpoint))
+	binary.Write(b, binary.LittleEndian, uint16(typeLONG))
+	binary.Write(b, binary.LittleEndian, uint32(1))
+	binary.Write(b, binary.LittleEndian, baseViewpoint)
+
+	// next‑IFD offset = 0
+	binary.Write(b, binary.LittleEndian, uint32(0))
+}
+
+// finishSegment fills in the APP2 length (bytes after marker).
+func finishSegment(data []byte) []byte {
+	segLen := len(data) - 2
+	data[2] = byte(segLen >> 8)
+	data[3] = byte(segLen)
+
+	return data
+}
+
+// findJFIFEnd returns the length of an APP0/JFIF segment immediately after SOI.
+func findJFIFEnd(d []byte) int {
+	if len(d) < 4 || d[0] != 0xFF || d[1] != 0xE0 { // APP0?
+		return 0
+	}
+	l := int(d[2])<<8 | int(d[3])
+	if l >= 2 && len(d) >= l {
+		return l
+	}
+	return 0
+}
+
+// ── reading ────────────────────────────────────────────────────────────────
+
+const (
+	mpojpgAPP1 = 0xE1
+	mpojpgAPP2 = 0xE2
+	mpojpgSOS  = 0xDA // Start of Scan
+)
+
+// jpegSegment is a marker segment from the header of a JPEG stream.
+type jpegSegment struct {
+	marker byte
+	offset int64  // position of the 0xFF marker byte
+	data   []byte // payload following the length field
+}
+
+// readSegments reads the marker segments of the JPEG starting at start in r,
+// up to and including the SOS segment.
+func readSegments(r io.ReaderAt, start int64) ([]jpegSegment, error) {
+	buf := make([]byte, 4)
+	if _, err := r.ReadAt(buf[:2], start); err != nil {
+		return nil, err
+	}
+	if buf[0] != mpojpgMKR || buf[1] != mpojpgSOI {
+		return nil, errors.New("missing SOI")
+	}
+
+	var segs []jpegSegment
+	pos := start + 2
+	for {
+		if _, err := r.ReadAt(buf, pos); err != nil {
+			return nil, err
+		}
+		if buf[0] != mpojpgMKR {
+			return nil, fmt.Errorf("expected marker at offset %d", pos)
+		}
+		if buf[1] == mpojpgMKR { // fill byte
+			pos++
+			continue
+		}
+
+		l := int(buf[2])<<8 | int(buf[3])
+		if l < 2 {
+			return nil, fmt.Errorf("invalid segment length %d at offset %d", l, pos)
+		}
+		data := make([]byte, l-2)
+		if _, err := r.ReadAt(data, pos+4); err != nil {
+			return nil, err
+		}
+
+		segs = append(segs, jpegSegment{marker: buf[1], offset: pos, data: data})
+		if buf[1] == mpojpgSOS || buf[1] == mpojpgEOI {
+			return segs, nil
+		}
+		pos += 2 + int64(l)
+	}
+}
+
+// findMPF returns the first APP2/MPF segment in segs.
+func findMPF(segs []jpegSegment) (jpegSegment, bool) {
+	for _, s := range segs {
+		if s.marker == mpojpgAPP2 && bytes.HasPrefix(s.data, []byte{'M', 'P', 'F', 0x00}) {
+			return s, true
+		}
+	}
+	return jpegSegment{}, false
+}
+
+// ifdEntry is a single raw IFD field.
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	value []byte // the 4 byte value or offset field
+}
+
+// mpfData is the parsed content of an APP2/MPF segment.
+type mpfData struct {
+	order binary.ByteOrder
+	tiff  []byte // segment payload from the TIFF header on
+
+	index   map[uint16]ifdEntry // MP Index IFD, nil if absent
+	attr    map[uint16]ifdEntry // MP Attribute IFD, nil if absent
+	entries []mpEntry
+}
+
+// parseMPF parses an APP2/MPF payload, starting with the "MPF\0" identifier.
+func parseMPF(data []byte) (*mpfData, error) {
+	if len(data) < 4+tiffHeaderSize {
+		return nil, errors.New("MPF segment too short")
+	}
+	d := &mpfData{tiff: data[4:]}
+
+	switch string(d.tiff[:2]) {
+	case "II":
+		d.order = binary.LittleEndian
+	case "MM":
+		d.order = binary.BigEndian
+	default:
+		return nil, errors.New("invalid MPF endian marker")
+	}
+	if d.order.Uint16(d.tiff[2:]) != 0x002A {
+		return nil, errors.New("invalid MPF TIFF header")
+	}
+
+	ifd, next, err := d.readIFD(d.order.Uint32(d.tiff[4:]))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := ifd[tagNumImages]; !ok {
+		if _, ok := ifd[tagMPImageList]; !ok {
+			// no MP Index IFD – only images after the first
+			d.attr = ifd
+			return d, nil
+		}
+	}
+
+	d.index = ifd
+	if next != 0 {
+		if d.attr, _, err = d.readIFD(next); err != nil {
+			return nil, err
+		}
+	}
+
+	if list, ok := ifd[tagMPImageList]; ok {
+		if list.count%mpEntrySize != 0 {
+			return nil, fmt.Errorf("MP Image List size %d is not a multiple of %d", list.count, mpEntrySize)
+		}
+		raw, err := d.bytes(d.order.Uint32(list.value), list.count)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(raw); i += mpEntrySize {
+			d.entries = append(d.entries, mpEntry{
+				attr:   d.order.Uint32(raw[i:]),
+				size:   d.order.Uint32(raw[i+4:]),
+				offset: d.order.Uint32(raw[i+8:]),
+				dep1:   d.order.Uint16(raw[i+12:]),
+				dep2:   d.order.Uint16(raw[i+14:]),
+			})
+		}
+	}
+
+	return d, nil
+}
+
+// readIFD reads the IFD at off, returning its fields and the next‑IFD offset.
+func (d *mpfData) readIFD(off uint32) (map[uint16]ifdEntry, uint32, error) {
+	head, err := d.bytes(off, 2)
+	if err != nil {
+		return nil, 0, err
+	}
+	n := uint32(d.order.Uint16(head))
+
+	raw, err := d.bytes(off+2, n*ifdEntrySize+4)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ifd := make(map[uint16]ifdEntry, n)
+	for i := uint32(0); i < n; i++ {
+		e := raw[i*ifdEntrySize:]
+		ifd[d.order.Uint16(e)] = ifdEntry{
+			typ:   d.order.Uint16(e[2:]),
+			count: d.order.Uint32(e[4:]),
+			value: e[8:12],
+		}
+	}
+
+	return ifd, d.order.Uint32(raw[n*ifdEntrySize:]), nil
+}
+
+// bytes returns n bytes of the TIFF data at off.
+func (d *mpfData) bytes(off, n uint32) ([]byte, error) {
+	if uint64(off)+uint64(n) > uint64(len(d.tiff)) {
+		return nil, fmt.Errorf("MPF offset %d+%d beyond segment end", off, n)
+	}
+	return d.tiff[off : off+n], nil
+}
+
+// long returns the LONG value of tag from ifd.
+func (d *mpfData) long(ifd map[uint16]ifdEntry, tag uint16) (uint32, bool) {
+	e, ok := ifd[tag]
+	if !ok || e.typ != typeLONG || e.count != 1 {
+		return 0, false
+	}
+	return d.order.Uint32(e.value), true
+}
@@ -0,0 +1,67 @@
+package mpo
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// ErrInvalidFrame indicates that a frame index is out of range, or that the
+// same frame was chosen for both eyes.
+var ErrInvalidFrame = errors.New("invalid frame")
+
+// DefaultPair returns the indices in m.Image of the frames used as the left
+// and right eye by the renderers that take a stereo pair. For two frames
+// these are the first and second. For a multi-frame MPO they are the base
+// viewpoint, m.BaseViewpoint or else the representative image, and the next
+// viewpoint, or the previous one if the base viewpoint is the last; frames
+// are in viewpoint order, left to right, as DecodeAll returns them ordered
+// by MP Individual Image Number.
+func (m *MPO) DefaultPair() (left, right int) {
+	n := len(m.Image)
+	if n < 2 {
+		return 0, 0
+	}
+
+	base := m.Representative
+	if m.BaseViewpoint > 0 && m.BaseViewpoint <= n {
+		base = m.BaseViewpoint - 1
+	}
+	base = max(0, min(base, n-1))
+	if base == n-1 {
+		return base - 1, base
+	}
+	return base, base + 1
+}
+
+// viewPair returns m if it holds at most two frames, and otherwise an MPO of
+// its DefaultPair.
+func (m *MPO) viewPair() *MPO {
+	if len(m.Image) <= 2 {
+		return m
+	}
+	pair, _ := m.SelectPair(m.DefaultPair())
+	return pair
+}
+
+// SelectPair returns an MPO of two frames of m, the frame at index left as
+// the left eye and the one at right as the right. The result can be passed to
+// any renderer; the images are not copied. An error wrapping ErrInvalidFrame
+// is returned if either index is out of range or they are the same.
+func (m *MPO) SelectPair(left, right int) (*MPO, error) {
+	for _, i := range []int{left, right} {
+		if i < 0 || i >= len(m.Image) {
+			return nil, fmt.Errorf("frame %d out of range [0, %d): %w", i, len(m.Image), ErrInvalidFrame)
+		}
+	}
+	if left == right {
+		return nil, fmt.Errorf("left and right are both frame %d: %w", left, ErrInvalidFrame)
+	}
+
+	rep := 0
+	if m.Representative == right {
+		rep = 1
+	}
+
+	return &MPO{Image: []image.Image{m.Image[left], m.Image[right]}, Representative: rep}, nil
+}
@@ -0,0 +1,129 @@
+package mpo_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+func TestDefaultPair(t *testing.T) {
+	frame := image.NewGray(image.Rect(0, 0, 2, 2))
+	tests := []struct {
+		frames, rep, base int
+		left, right       int
+	}{
+		{2, 0, 0, 0, 1},
+		{2, 1, 0, 0, 1},
+		{3, 0, 0, 0, 1},
+		{3, 1, 0, 1, 2},
+		{4, 3, 0, 2, 3},
+		{4, 0, 2, 1, 2},
+		{4, 0, 4, 2, 3},
+		{3, 1, 5, 1, 2},
+	}
+
+	for _, tc := range tests {
+		m := &mpo.MPO{Image: make([]image.Image, tc.frames), Representative: tc.rep, BaseViewpoint: tc.base}
+		for i := range m.Image {
+			m.Image[i] = frame
+		}
+		if l, r := m.DefaultPair(); l != tc.left || r != tc.right {
+			t.Errorf("%d frames, representative %d, base viewpoint %d: DefaultPair = %d, %d, want %d, %d",
+				tc.frames, tc.rep, tc.base, l, r, tc.left, tc.right)
+		}
+	}
+}
+
+func TestSelectPair(t *testing.T) {
+	shades := []uint8{0, 100, 200}
+	frames := make([]image.Image, len(shades))
+	for i, v := range shades {
+		frames[i] = solid(4, 4, color.RGBA{v, v, v, 255})
+	}
+	m := &mpo.MPO{Image: frames, Representative: 1}
+
+	// the default pair of three views starts at the representative image
+	ana, err := m.ConvertToAnaglyph(mpo.RedCyan)
+	if err != nil {
+		t.Fatalf("ConvertToAnaglyph failed: %v", err)
+	}
+	if r, g, _, _ := ana.At(0, 0).RGBA(); r>>8 != 100 || g>>8 != 200 {
+		t.Errorf("default pair anaglyph = %d, %d, want red from frame 1 and cyan from frame 2", r>>8, g>>8)
+	}
+
+	pair, err := m.SelectPair(2, 0)
+	if err != nil {
+		t.Fatalf("SelectPair failed: %v", err)
+	}
+	ana, err = pair.ConvertToAnaglyph(mpo.RedCyan)
+	if err != nil {
+		t.Fatalf("ConvertToAnaglyph failed: %v", err)
+	}
+	if r, g, _, _ := ana.At(0, 0).RGBA(); r>>8 != 200 || g>>8 != 0 {
+		t.Errorf("selected pair anaglyph = %d, %d, want red from frame 2 and cyan from frame 0", r>>8, g>>8)
+	}
+
+	for _, lr := range [][2]int{{0, 3}, {-1, 1}, {1, 1}} {
+		if _, err := m.SelectPair(lr[0], lr[1]); !errors.Is(err, mpo.ErrInvalidFrame) {
+			t.Errorf("SelectPair(%d, %d) error = %v, want ErrInvalidFrame", lr[0], lr[1], err)
+		}
+	}
+}
+
+func TestRepresentativeRoundTrip(t *testing.T) {
+	frames := make([]image.Image, 3)
+	for i := range frames {
+		frames[i] = solid(8, 8, color.RGBA{uint8(80 * i), 0, 0, 255})
+	}
+
+	var buf bytes.Buffer
+	if err := mpo.EncodeAll(&buf, &mpo.MPO{Image: frames, Representative: 2}, nil); err != nil {
+		t.Fatalf("EncodeAll failed: %v", err)
+	}
+
+	decoded, err := mpo.DecodeAll(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("DecodeAll failed: %v", err)
+	}
+	if decoded.Representative != 2 {
+		t.Errorf("Representative = %d, want 2", decoded.Representative)
+	}
+	if r, _, _, _ := decoded.Image[2].At(4, 4).RGBA(); absDiff(uint8(r>>8), 160) > 10 {
+		t.Errorf("frame 2 red = %d, want 160", r>>8)
+	}
+	// the representative image is recorded as the base viewpoint
+	if decoded.BaseViewpoint != 3 {
+		t.Errorf("BaseViewpoint = %d, want 3", decoded.BaseViewpoint)
+	}
+}
+
+func TestBaseViewpointRoundTrip(t *testing.T) {
+	frames := make([]image.Image, 4)
+	for i := range frames {
+		frames[i] = solid(8, 8, color.RGBA{uint8(60 * i), 0, 0, 255})
+	}
+
+	var buf bytes.Buffer
+	if err := mpo.EncodeAll(&buf, &mpo.MPO{Image: frames, BaseViewpoint: 2}, nil); err != nil {
+		t.Fatalf("EncodeAll failed: %v", err)
+	}
+
+	decoded, err := mpo.DecodeAll(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("DecodeAll failed: %v", err)
+	}
+	if decoded.Representative != 0 || decoded.BaseViewpoint != 2 {
+		t.Errorf("Representative, BaseViewpoint = %d, %d, want 0, 2", decoded.Representative, decoded.BaseViewpoint)
+	}
+	if l, r := decoded.DefaultPair(); l != 1 || r != 2 {
+		t.Errorf("DefaultPair = %d, %d, want 1, 2", l, r)
+	}
+
+	if err := mpo.EncodeAll(&buf, &mpo.MPO{Image: frames, BaseViewpoint: 5}, nil); err == nil {
+		t.Error("expected error for out of range base viewpoint, got nil")
+	}
+}
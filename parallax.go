@@ -0,0 +1,68 @@
+package mpo
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// ShiftParallax returns a copy of m with the frames translated horizontally
+// relative to each other by px pixels, and each cropped to the area they
+// share, moving the stereo window. The images are not copied; the frames of
+// the result are sub-images of those of m where possible.
+//
+// A positive px increases the parallax, placing the scene further behind the
+// screen: the left frame loses px columns on its left edge and the right
+// frame px columns on its right edge. A negative px brings the scene
+// forward. With more than two frames each neighbouring pair is shifted by
+// px, so frame i loses (n-1-i)·px columns on the left and i·px on the right.
+//
+// The result can be passed to ConvertToStereo, ConvertToAnaglyph and the
+// other renderers.
+func (m *MPO) ShiftParallax(px int) (*MPO, error) {
+	out := &MPO{Image: make([]image.Image, len(m.Image)), Representative: m.Representative, BaseViewpoint: m.BaseViewpoint}
+	copy(out.Image, m.Image)
+	if px == 0 || len(m.Image) < 2 {
+		return out, nil
+	}
+
+	n := len(m.Image)
+	for i, img := range m.Image {
+		left, right := (n-1-i)*px, i*px
+		if px < 0 {
+			left, right = -i*px, -(n-1-i)*px
+		}
+
+		if left+right >= img.Bounds().Dx() {
+			return nil, fmt.Errorf("parallax shift of %d pixels leaves nothing of the %d pixel wide image %d", px, img.Bounds().Dx(), i)
+		}
+		out.Image[i] = cropX(img, left, right)
+	}
+
+	return out, nil
+}
+
+// cropX returns img with left and right columns removed.
+func cropX(img image.Image, left, right int) image.Image {
+	b := img.Bounds()
+	return crop(img, image.Rect(b.Min.X+left, b.Min.Y, b.Max.X-right, b.Max.Y))
+}
+
+// crop returns the part of img within r, sharing its pixels if possible.
+func crop(img image.Image, r image.Rectangle) image.Image {
+	r = r.Intersect(img.Bounds())
+	if r.Empty() {
+		return image.NewRGBA(image.Rectangle{})
+	}
+
+	if si, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return si.SubImage(r)
+	}
+
+	dst := image.NewRGBA(r)
+	draw.Draw(dst, r, img, r.Min, draw.Src)
+
+	return dst
+}
@@ -0,0 +1,70 @@
+package mpo_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+func TestShiftParallax(t *testing.T) {
+	// Each frame is 4×1 with column x holding gray value 10·x (left) or 100+10·x (right).
+	left := image.NewGray(image.Rect(0, 0, 4, 1))
+	right := image.NewGray(image.Rect(0, 0, 4, 1))
+	for x := range 4 {
+		left.SetGray(x, 0, color.Gray{uint8(10 * x)})
+		right.SetGray(x, 0, color.Gray{uint8(100 + 10*x)})
+	}
+	m := &mpo.MPO{Image: []image.Image{left, right}}
+
+	tests := []struct {
+		name        string
+		px          int
+		left, right []uint8
+	}{
+		{"none", 0, []uint8{0, 10, 20, 30}, []uint8{100, 110, 120, 130}},
+		{"behind", 1, []uint8{10, 20, 30}, []uint8{100, 110, 120}},
+		{"forward", -2, []uint8{0, 10}, []uint8{120, 130}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			shifted, err := m.ShiftParallax(tc.px)
+			if err != nil {
+				t.Fatalf("ShiftParallax failed: %v", err)
+			}
+
+			stereo := shifted.ConvertToStereo()
+			want := append(tc.left, tc.right...)
+			if dx := stereo.Bounds().Dx(); dx != len(want) {
+				t.Fatalf("stereo width = %d, want %d", dx, len(want))
+			}
+			for x, v := range want {
+				if got := color.GrayModel.Convert(stereo.At(x, 0)).(color.Gray).Y; got != v {
+					t.Errorf("pixel %d = %d, want %d", x, got, v)
+				}
+			}
+		})
+	}
+
+	shifted, err := m.ShiftParallax(1)
+	if err != nil {
+		t.Fatalf("ShiftParallax failed: %v", err)
+	}
+	ana, err := shifted.ConvertToAnaglyph(mpo.RedCyan)
+	if err != nil {
+		t.Fatalf("ConvertToAnaglyph of shifted frames failed: %v", err)
+	}
+	if dx := ana.Bounds().Dx(); dx != 3 {
+		t.Errorf("anaglyph width = %d, want 3", dx)
+	}
+	// green comes from the right frame, whose first remaining column is 100
+	if _, g, _, _ := ana.At(ana.Bounds().Min.X, 0).RGBA(); g>>8 != 100 {
+		t.Errorf("anaglyph green = %d, want 100", g>>8)
+	}
+
+	if _, err := m.ShiftParallax(4); err == nil {
+		t.Error("expected error for a shift as wide as the frames, got nil")
+	}
+}
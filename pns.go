@@ -0,0 +1,35 @@
+package mpo
+
+import (
+	"image/png"
+	"io"
+)
+
+// EncodePNS writes the DefaultPair of m to w as a PNS (PNG Stereo) file: a
+// lossless side-by-side PNG with the right eye's frame on the left, the
+// cross-eyed order PNS viewers expect.
+//
+// ErrInvalidImageCount is returned if m has fewer than 2 frames.
+func EncodePNS(w io.Writer, m *MPO) error {
+	if len(m.Image) < 2 {
+		return ErrInvalidImageCount
+	}
+	pair, err := m.SelectPair(m.DefaultPair())
+	if err != nil {
+		return err
+	}
+
+	return png.Encode(w, pair.composeStereo(&StereoOptions{Layout: CrossEyed}))
+}
+
+// DecodePNS reads a PNS (PNG Stereo) file from r and splits it into an MPO of
+// its left and right frames, taking the right eye's frame from the left half
+// of the image.
+func DecodePNS(r io.Reader) (*MPO, error) {
+	img, err := png.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return Split(img, &SplitOptions{Layout: CrossEyed})
+}
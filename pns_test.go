@@ -0,0 +1,60 @@
+package mpo_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+func TestPNSRoundTrip(t *testing.T) {
+	left := image.NewRGBA(image.Rect(0, 0, 6, 4))
+	right := image.NewRGBA(image.Rect(0, 0, 6, 4))
+	for y := range 4 {
+		for x := range 6 {
+			left.Set(x, y, color.RGBA{uint8(10 * x), uint8(10 * y), 1, 255})
+			right.Set(x, y, color.RGBA{uint8(10 * x), uint8(10 * y), 2, 255})
+		}
+	}
+	m := &mpo.MPO{Image: []image.Image{left, right}}
+
+	var buf bytes.Buffer
+	if err := mpo.EncodePNS(&buf, m); err != nil {
+		t.Fatalf("EncodePNS failed: %v", err)
+	}
+
+	sbs, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("png.Decode failed: %v", err)
+	}
+	if b := sbs.Bounds(); b.Dx() != 12 || b.Dy() != 4 {
+		t.Fatalf("PNS image is %v, want 12x4", b)
+	}
+	if _, _, b, _ := sbs.At(0, 0).RGBA(); b>>8 != 2 {
+		t.Errorf("left half blue = %d, want the right frame's 2", b>>8)
+	}
+
+	decoded, err := mpo.DecodePNS(&buf)
+	if err != nil {
+		t.Fatalf("DecodePNS failed: %v", err)
+	}
+	if len(decoded.Image) != 2 {
+		t.Fatalf("got %d frames, want 2", len(decoded.Image))
+	}
+
+	// PNG is lossless, so the frames come back exactly
+	for i, want := range []*image.RGBA{left, right} {
+		got := decoded.Image[i]
+		gb := got.Bounds()
+		for y := range 4 {
+			for x := range 6 {
+				if g, w := color.RGBAModel.Convert(got.At(gb.Min.X+x, gb.Min.Y+y)), want.At(x, y); g != w {
+					t.Fatalf("frame %d pixel %d,%d = %v, want %v", i, x, y, g, w)
+				}
+			}
+		}
+	}
+}
@@ -15,14 +15,37 @@
 // The package offers:
 //
 //   - DecodeAll  – extract every JPEG frame present in an MPO.
-//   - EncodeAll  – write a Baseline‑MP MPO from a slice of image.Image.
+//   - EncodeAll  – write an MPO from a slice of image.Image.
 //   - ConvertToStereo   – merge the first two frames side‑by‑side.
-//   - ConvertToAnaglyph – create red/cyan or similar anaglyphs.
+//   - ConvertToAnaglyph – create red/cyan or similar anaglyphs, by the
+//     true, grey, colour, half-colour, optimized or Dubois methods, or by
+//     custom matrices.
+//   - ConvertToInterleaved – interleave rows, columns or a checkerboard.
+//   - ConvertToCard – lay the frames out on a printable stereo card.
+//   - ConvertToLenticular – interlace the frames for a lenticular print.
+//   - ConvertToVR – pre-distort the frames for a phone-based VR viewer.
+//   - ConvertToWiggle – animate the frames as a wiggle GIF.
+//   - SelectPair – choose the frames of a multi-frame MPO to use as a pair.
+//   - Resize – fit the frames to a target size before converting.
+//   - ShiftParallax – move the stereo window before converting.
+//   - Align – correct vertical misalignment between the frames of a pair.
+//   - AutoParallax – estimate disparities and pick the stereo window.
+//   - ComputeDisparityMap – estimate a dense disparity (depth) map.
+//   - Split – split a side-by-side or over/under image into an MPO.
+//   - EncodeJPS, DecodeJPS – convert to and from JPEG Stereo files.
+//   - EncodePNS, DecodePNS – convert to and from PNG Stereo files.
+//   - Validate  – check an MPO file against the specification.
 //
-// EncodeAll produces only the subset required for a Baseline‑MP file: the
-// first frame is flagged as the representative image and is given MP type
-// 0x00030000. DecodeAll imposes no such restriction and simply returns every
-// JPEG it finds.
+// EncodeAll produces only a small subset of the format: MPO.Representative,
+// the first frame by default, is written first as the Baseline MP Primary
+// Image (MP type 0x030000) flagged as the representative image, and the
+// other frames follow as Multi-Frame Disparity images (0x020002) for a
+// stereo pair or Multi-Angle images (0x020003) for more frames. Every frame
+// records its position and the base viewpoint in an MP Attribute IFD.
+// EncodeAllWithOptions can also add Large Thumbnail previews and Exif
+// segments. DecodeAll imposes no such restriction and returns every JPEG it
+// finds, using the MP Index IFD to locate and order them, to skip Large
+// Thumbnails and to find the representative image when present.
 //
 // Specification references:
 //
@@ -33,8 +56,8 @@
 //
 // Offsets in the MP Image List are measured relative to the TIFF endian
 // marker inside the APP2/MPF segment, as required by DC‑X007 §5.2.3.3.
-// The code relies only on the Go standard library and is safe for pure‑Go
-// builds.
+// The code relies only on the Go standard library and golang.org/x/image and
+// is safe for pure‑Go builds.
 package mpo
 
 import (
@@ -43,6 +66,7 @@ import (
 	"image"
 	"image/jpeg"
 	"io"
+	"sort"
 )
 
 // ErrNoImages indicates that no images were found in the specified file.
@@ -51,6 +75,19 @@ var ErrNoImages = errors.New("no images found in mpo image")
 // MPO represents the likely multiple images stored in a MPO file.
 type MPO struct {
 	Image []image.Image
+
+	// Representative is the index in Image of the frame flagged as the
+	// representative image, which DecodeAll reads from the MP Entries and
+	// EncodeAll writes back. It also chooses the default stereo pair of
+	// multi-frame MPOs; see DefaultPair.
+	Representative int
+
+	// BaseViewpoint is the position in Image, counting from 1, of the frame
+	// of the base viewpoint of a multi-view MPO, which DecodeAll reads from
+	// the BaseViewpointNum tag of the MP Attribute IFDs and EncodeAll writes
+	// back. Zero means none is recorded and the representative image is the
+	// base viewpoint.
+	BaseViewpoint int
 }
 
 const (
@@ -79,9 +116,10 @@ func DecodeAll(rr io.Reader) (*MPO, error) {
 	readData := make([]byte, 1)
 
 	var (
-		depth    uint8
-		imgStart int64
-		loc      int64
+		depth      uint8
+		imgStart   int64
+		firstStart int64 = -1
+		loc        int64
 	)
 
 	for {
@@ -105,6 +143,9 @@ func DecodeAll(rr io.Reader) (*MPO, error) {
 			if readData[0] == mpojpgSOI {
 				if depth == 0 {
 					imgStart = loc - 2
+					if firstStart < 0 {
+						firstStart = imgStart
+					}
 				}
 
 				depth++
@@ -122,6 +163,14 @@ func DecodeAll(rr io.Reader) (*MPO, error) {
 		Image: make([]image.Image, 0),
 	}
 
+	// prefer the MP Index IFD, which locates frames exactly, over the scan
+	if firstStart >= 0 {
+		if located, rep, base, ok := locateFrames(rAt, firstStart); ok {
+			sectReaders = located
+			m.Representative, m.BaseViewpoint = rep, base
+		}
+	}
+
 	for _, s := range sectReaders {
 		img, err := jpeg.Decode(s)
 		if err != nil {
@@ -134,6 +183,115 @@ func DecodeAll(rr io.Reader) (*MPO, error) {
 	return m, nil
 }
 
+// locateFrames returns a reader for every frame listed in the MP Index IFD of
+// the JPEG at start, ordered by MP Individual Image Number when every frame
+// records a distinct one, the index of the representative image among them
+// and, counting from 1, that of the frame whose number is the recorded Base
+// Viewpoint Number, or 0 if there is none. Large Thumbnails are previews of
+// another frame and are skipped. ok is false if the file has no usable MP
+// Index IFD.
+func locateFrames(r io.ReaderAt, start int64) (frames []*io.SectionReader, rep, base int, ok bool) {
+	segs, err := readSegments(r, start)
+	if err != nil {
+		return nil, 0, 0, false
+	}
+	seg, found := findMPF(segs)
+	if !found {
+		return nil, 0, 0, false
+	}
+	mpf, err := parseMPF(seg.data)
+	if err != nil || len(mpf.entries) == 0 {
+		return nil, 0, 0, false
+	}
+
+	// Each frame runs to the start of the next or the end of the file rather
+	// than its MP Entry size, which older versions of this package recorded
+	// without the APP2/MPF segment of the first image.
+	endian := seg.offset + 8 // APP2 marker, length and "MPF\0"
+	starts := make([]int64, len(mpf.entries))
+	for i, e := range mpf.entries {
+		starts[i] = start
+		if e.offset != 0 {
+			starts[i] = endian + int64(e.offset)
+		}
+	}
+	end := func(pos int64) int64 {
+		next := int64(1<<63 - 1)
+		for _, s := range starts {
+			if s > pos {
+				next = min(next, s)
+			}
+		}
+		return next
+	}
+
+	nums := make([]uint32, 0, len(mpf.entries))
+	seen := make(map[uint32]bool, len(mpf.entries))
+	var baseNum uint32
+	for i, e := range mpf.entries {
+		if isLargeThumbnail(e) {
+			continue
+		}
+		if e.attr&flagRepresentative != 0 {
+			rep = len(frames)
+		}
+
+		pos := starts[i]
+		soi := make([]byte, 2)
+		if _, err := r.ReadAt(soi, pos); err != nil || soi[0] != mpojpgMKR || soi[1] != mpojpgSOI || e.size == 0 {
+			return nil, 0, 0, false
+		}
+		frames = append(frames, io.NewSectionReader(r, pos, end(pos)-pos))
+
+		src, attr := mpf, mpf.attr
+		if pos != start {
+			attr = nil
+			if segs, err := readSegments(r, pos); err == nil {
+				if seg, found := findMPF(segs); found {
+					if d, err := parseMPF(seg.data); err == nil {
+						src, attr = d, d.attr
+					}
+				}
+			}
+		}
+		n, _ := src.long(attr, tagMPIndividualNum)
+		nums = append(nums, n)
+		seen[n] = true
+		if b, ok := src.long(attr, tagBaseViewpoint); ok && baseNum == 0 {
+			baseNum = b
+		}
+	}
+
+	if len(frames) == 0 {
+		return nil, 0, 0, false
+	}
+
+	// without distinct numbers the frames stay in file order, and the base
+	// viewpoint cannot be found among them
+	if len(seen) != len(frames) || seen[0] {
+		return frames, rep, 0, true
+	}
+
+	sorted := make([]*io.SectionReader, len(frames))
+	idx := make([]int, len(frames))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return nums[idx[a]] < nums[idx[b]] })
+	newRep := rep
+	for i, j := range idx {
+		sorted[i] = frames[j]
+		if j == rep {
+			newRep = i
+		}
+		if baseNum != 0 && nums[j] == baseNum {
+			base = i + 1
+		}
+	}
+
+	return sorted, newRep, base, true
+}
+
 // Decode reads a MPO image from r and returns it as an image.Image.
 func Decode(r io.Reader) (image.Image, error) {
 	all, err := DecodeAll(r)
@@ -164,8 +322,8 @@ func DecodeConfig(r io.Reader) (image.Config, error) {
 
 	return image.Config{
 		ColorModel: all.Image[0].ColorModel(),
-		Width:      all.Image[0].Bounds().Max.X,
-		Height:     all.Image[0].Bounds().Max.Y,
+		Width:      all.Image[0].Bounds().Dx(),
+		Height:     all.Image[0].Bounds().Dy(),
 	}, nil
 }
 
@@ -0,0 +1,34 @@
+package mpo_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+// testdata/legacy.mpo was written by the first release of EncodeAll, which
+// recorded the size of the first image without its APP2/MPF segment.
+func TestDecodeAll_LegacySizes(t *testing.T) {
+	f, err := os.Open("testdata/legacy.mpo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	m, err := mpo.DecodeAll(f)
+	if err != nil {
+		t.Fatalf("DecodeAll failed: %v", err)
+	}
+	if got := len(m.Image); got != 2 {
+		t.Fatalf("expected 2 images, got %d", got)
+	}
+
+	// a red then a blue 16×16 frame
+	for i, wantRed := range []bool{true, false} {
+		r, _, b, _ := m.Image[i].At(8, 8).RGBA()
+		if red := r > 0x8000 && b < 0x8000; red != wantRed {
+			t.Errorf("frame %d = %v, want red %v", i, m.Image[i].At(8, 8), wantRed)
+		}
+	}
+}
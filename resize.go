@@ -0,0 +1,54 @@
+package mpo
+
+import (
+	"fmt"
+	"image"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Resize returns a copy of m with every frame scaled by interp, keeping its
+// aspect ratio, to fit within width×height pixels, up or down. A zero width
+// or height leaves that dimension unconstrained and a nil interp uses
+// draw.CatmullRom. It suits the renderers that keep the size of the frames,
+// such as ConvertToAnaglyph and ConvertToInterleaved; ConvertToStereoWithOptions
+// fits the whole layout with StereoOptions.Width and Height instead.
+func (m *MPO) Resize(width, height int, interp xdraw.Interpolator) (*MPO, error) {
+	if width < 0 || height < 0 || width > MaxImageDimension || height > MaxImageDimension {
+		return nil, fmt.Errorf("invalid size %dx%d", width, height)
+	}
+	if interp == nil {
+		interp = xdraw.CatmullRom
+	}
+
+	out := &MPO{Image: make([]image.Image, len(m.Image)), Representative: m.Representative, BaseViewpoint: m.BaseViewpoint}
+	copy(out.Image, m.Image)
+	if width == 0 && height == 0 {
+		return out, nil
+	}
+
+	for i, img := range m.Image {
+		b := img.Bounds()
+		if b.Empty() {
+			continue
+		}
+
+		w, h := fitSize(b.Dx(), b.Dy(), width, height)
+		if w != b.Dx() || h != b.Dy() {
+			out.Image[i] = scaleWith(interp, img, w, h)
+		}
+	}
+
+	return out, nil
+}
+
+// fitSize returns the largest size with the aspect ratio of w×h that fits
+// within width×height, where zero leaves a dimension unconstrained.
+func fitSize(w, h, width, height int) (int, int) {
+	fw, fh := width, h*width/w
+	if width == 0 || (height > 0 && fh > height) {
+		fw, fh = w*height/h, height
+	}
+
+	return max(fw, 1), max(fh, 1)
+}
@@ -0,0 +1,121 @@
+package mpo_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/donatj/mpo"
+	xdraw "golang.org/x/image/draw"
+)
+
+func TestResize(t *testing.T) {
+	m := &mpo.MPO{
+		Image:          []image.Image{solid(400, 300, color.RGBA{255, 0, 0, 255}), solid(400, 300, color.RGBA{0, 0, 255, 255})},
+		Representative: 1,
+	}
+
+	tests := []struct {
+		width, height int
+		want          image.Point
+	}{
+		{200, 0, image.Pt(200, 150)},
+		{0, 600, image.Pt(800, 600)},
+		{200, 200, image.Pt(200, 150)},
+		{1000, 300, image.Pt(400, 300)},
+		{0, 0, image.Pt(400, 300)},
+	}
+	for _, tc := range tests {
+		r, err := m.Resize(tc.width, tc.height, xdraw.ApproxBiLinear)
+		if err != nil {
+			t.Fatalf("Resize(%d, %d) failed: %v", tc.width, tc.height, err)
+		}
+		if r.Representative != 1 {
+			t.Errorf("Resize(%d, %d) representative = %d, want 1", tc.width, tc.height, r.Representative)
+		}
+		for i, img := range r.Image {
+			if got := img.Bounds().Size(); got != tc.want {
+				t.Errorf("Resize(%d, %d) frame %d = %v, want %v", tc.width, tc.height, i, got, tc.want)
+			}
+		}
+	}
+
+	if _, err := m.Resize(-1, 0, nil); err == nil {
+		t.Error("expected error for a negative width")
+	}
+}
+
+func TestConvertToStereoWithOptions_Size(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	m := &mpo.MPO{Image: []image.Image{solid(400, 300, red), solid(400, 300, blue)}}
+
+	tests := []struct {
+		name   string
+		opts   mpo.StereoOptions
+		size   image.Point
+		left   image.Point
+		right  image.Point
+		border image.Point
+	}{
+		{
+			// each eye is 16:9 once stretched back, so the 4:3 frames are pillarboxed
+			name:   "half-sbs 1920x1080",
+			opts:   mpo.StereoOptions{Layout: mpo.HalfSideBySide, Width: 1920, Height: 1080},
+			size:   image.Pt(1920, 1080),
+			left:   image.Pt(480, 540),
+			right:  image.Pt(1440, 540),
+			border: image.Pt(50, 540),
+		},
+		{
+			name:   "stereo width only",
+			opts:   mpo.StereoOptions{Width: 1280},
+			size:   image.Pt(1280, 480),
+			left:   image.Pt(320, 240),
+			right:  image.Pt(960, 240),
+			border: image.Pt(-1, -1),
+		},
+		{
+			name:   "cross-eyed letterboxed",
+			opts:   mpo.StereoOptions{Layout: mpo.CrossEyed, Width: 800, Height: 400},
+			size:   image.Pt(800, 400),
+			left:   image.Pt(600, 200),
+			right:  image.Pt(200, 200),
+			border: image.Pt(400, 20),
+		},
+		{
+			name:   "over-under height only",
+			opts:   mpo.StereoOptions{Layout: mpo.OverUnder, Height: 300, Interpolator: xdraw.NearestNeighbor},
+			size:   image.Pt(200, 300),
+			left:   image.Pt(100, 75),
+			right:  image.Pt(100, 225),
+			border: image.Pt(-1, -1),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := m.ConvertToStereoWithOptions(&tc.opts)
+			if err != nil {
+				t.Fatalf("ConvertToStereoWithOptions failed: %v", err)
+			}
+			if got := img.Bounds().Size(); got != tc.size {
+				t.Fatalf("size = %v, want %v", got, tc.size)
+			}
+			if c := color.RGBAModel.Convert(img.At(tc.left.X, tc.left.Y)); c != red {
+				t.Errorf("left eye at %v = %v, want %v", tc.left, c, red)
+			}
+			if c := color.RGBAModel.Convert(img.At(tc.right.X, tc.right.Y)); c != blue {
+				t.Errorf("right eye at %v = %v, want %v", tc.right, c, blue)
+			}
+			if tc.border.X >= 0 {
+				if _, _, _, a := img.At(tc.border.X, tc.border.Y).RGBA(); a != 0 {
+					t.Errorf("border at %v is not empty", tc.border)
+				}
+			}
+		})
+	}
+
+	if _, err := m.ConvertToStereoWithOptions(&mpo.StereoOptions{Height: -1}); err == nil {
+		t.Error("expected error for a negative height")
+	}
+}
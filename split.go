@@ -0,0 +1,205 @@
+package mpo
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// SplitOptions are the parameters used by Split.
+type SplitOptions struct {
+	// Layout is the arrangement of the frames in the image, as produced by
+	// ConvertToStereoWithOptions.
+	Layout StereoLayout
+
+	// Gap is the width in pixels of a divider between the frames, removed
+	// before splitting.
+	Gap int
+
+	// TrimGap detects and removes a divider of uniform colour between the
+	// frames, and any uniform border around them, as found on scanned
+	// stereo cards. It is applied after Gap.
+	TrimGap bool
+}
+
+const (
+	trimMaxStdDev   = 10 // largest standard deviation of a uniform line, per 8-bit channel
+	trimMaxDistance = 40 // largest difference from the colour of the first uniform line
+)
+
+// Split splits a single image holding a stereo pair, such as a side-by-side
+// photo from a phone or a scanned stereo card, into an MPO of its left and
+// right frames. Frames of the half size layouts are scaled back to their full
+// size. If the two halves differ in size after trimming, the larger is
+// cropped on its outer edge to match. A nil o splits a side-by-side image.
+//
+// ErrUnsupportedLayout is returned if the layout is not supported and
+// ErrNoImages if nothing is left of the image to split.
+func Split(img image.Image, o *SplitOptions) (*MPO, error) {
+	if o == nil {
+		o = &SplitOptions{}
+	}
+	if o.Layout < SideBySide || o.Layout > HalfOverUnder {
+		return nil, fmt.Errorf("unsupported layout %d: %w", o.Layout, ErrUnsupportedLayout)
+	}
+
+	vertical := o.Layout == OverUnder || o.Layout == HalfOverUnder
+	s := splitter{img: img, vertical: vertical}
+
+	// work in along/across coordinates: along runs from one frame to the
+	// other, across the other way
+	b := img.Bounds()
+	lo, hi, across0, across1 := b.Min.X, b.Max.X, b.Min.Y, b.Max.Y
+	if vertical {
+		lo, hi, across0, across1 = b.Min.Y, b.Max.Y, b.Min.X, b.Max.X
+	}
+
+	if o.TrimGap {
+		s.across = false
+		lo, hi = s.trimEdges(lo, hi, across0, across1)
+		s.across = true
+		across0, across1 = s.trimEdges(across0, across1, lo, hi)
+		s.across = false
+	}
+
+	mid := lo + (hi-lo)/2
+	end1, start2 := mid-o.Gap/2, mid+(o.Gap+1)/2
+	if o.TrimGap {
+		end1, start2 = s.findGap(end1, start2, lo, hi, across0, across1)
+	}
+
+	n := min(end1-lo, hi-start2)
+	if n <= 0 || across1 <= across0 {
+		return nil, fmt.Errorf("nothing left of %v to split: %w", b, ErrNoImages)
+	}
+
+	rect := func(from, to int) image.Rectangle {
+		if vertical {
+			return image.Rect(across0, from, across1, to)
+		}
+		return image.Rect(from, across0, to, across1)
+	}
+	first := crop(img, rect(end1-n, end1))
+	second := crop(img, rect(start2, start2+n))
+
+	switch o.Layout {
+	case HalfSideBySide:
+		first, second = scale(first, 2*n, across1-across0), scale(second, 2*n, across1-across0)
+	case HalfOverUnder:
+		first, second = scale(first, across1-across0, 2*n), scale(second, across1-across0, 2*n)
+	}
+
+	if o.Layout == CrossEyed {
+		first, second = second, first
+	}
+
+	return &MPO{Image: []image.Image{first, second}}, nil
+}
+
+// splitter examines the lines of pixels of an image along which it is split.
+type splitter struct {
+	img      image.Image
+	vertical bool // frames are over/under rather than side by side
+	across   bool // examine lines running across the split rather than along
+}
+
+// line returns the mean colour of line i, from a to b, and whether it is
+// uniform.
+func (s *splitter) line(i, a, b int) (mean [3]float64, uniform bool) {
+	var sum, sq [3]float64
+	for j := a; j < b; j++ {
+		x, y := i, j
+		if s.vertical != s.across {
+			x, y = j, i
+		}
+		r, g, bl, _ := s.img.At(x, y).RGBA()
+		for k, v := range [3]uint32{r, g, bl} {
+			f := float64(v >> 8)
+			sum[k] += f
+			sq[k] += f * f
+		}
+	}
+
+	n := float64(max(b-a, 1))
+	uniform = true
+	for k := range 3 {
+		mean[k] = sum[k] / n
+		if math.Sqrt(max(sq[k]/n-mean[k]*mean[k], 0)) > trimMaxStdDev {
+			uniform = false
+		}
+	}
+	return mean, uniform
+}
+
+// similar reports whether two colours are close enough to belong to the
+// same border.
+func similar(a, b [3]float64) bool {
+	return math.Abs(a[0]-b[0])+math.Abs(a[1]-b[1])+math.Abs(a[2]-b[2]) <= trimMaxDistance
+}
+
+// trimEdges returns lo and hi moved inwards past uniform lines of a common
+// colour on either edge, examining each line from a to b. At most a quarter
+// of the lines are trimmed from each edge.
+func (s *splitter) trimEdges(lo, hi, a, b int) (int, int) {
+	limit := (hi - lo) / 4
+
+	if c, ok := s.line(lo, a, b); ok {
+		for n := 0; n < limit; n++ {
+			if m, ok := s.line(lo, a, b); !ok || !similar(m, c) {
+				break
+			}
+			lo++
+		}
+	}
+	if c, ok := s.line(hi-1, a, b); ok {
+		for n := 0; n < limit; n++ {
+			if m, ok := s.line(hi-1, a, b); !ok || !similar(m, c) {
+				break
+			}
+			hi--
+		}
+	}
+
+	return lo, hi
+}
+
+// findGap returns the edges of the band of uniform lines of a common colour
+// nearest to end1 and start2, within the central fifth of lo to hi,
+// examining each line from a to b. end1 and start2 are returned unchanged if
+// there is none.
+func (s *splitter) findGap(end1, start2, lo, hi, a, b int) (int, int) {
+	mid := lo + (hi-lo)/2
+	reach := (hi - lo) / 10
+
+	seed := -1
+	for d := 0; d <= reach && seed < 0; d++ {
+		for _, i := range []int{mid - 1 - d, mid + d} {
+			if i >= lo && i < hi {
+				if _, ok := s.line(i, a, b); ok {
+					seed = i
+					break
+				}
+			}
+		}
+	}
+	if seed < 0 {
+		return end1, start2
+	}
+
+	c, _ := s.line(seed, a, b)
+	gap0, gap1 := seed, seed+1
+	for gap0 > lo {
+		if m, ok := s.line(gap0-1, a, b); !ok || !similar(m, c) {
+			break
+		}
+		gap0--
+	}
+	for gap1 < hi {
+		if m, ok := s.line(gap1, a, b); !ok || !similar(m, c) {
+			break
+		}
+		gap1++
+	}
+
+	return min(end1, gap0), max(start2, gap1)
+}
@@ -0,0 +1,123 @@
+package mpo_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+// noise returns a w×h image of random colours that are never close to white.
+func noise(w, h int, seed int64) *image.RGBA {
+	rnd := rand.New(rand.NewSource(seed))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(30 + rnd.Intn(150))
+		if i%4 == 3 {
+			img.Pix[i] = 255
+		}
+	}
+	return img
+}
+
+func TestSplit(t *testing.T) {
+	red, green := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}
+	pair := &mpo.MPO{Image: []image.Image{solid(20, 10, red), solid(20, 10, green)}}
+
+	layouts := []struct {
+		name   string
+		layout mpo.StereoLayout
+	}{
+		{"side-by-side", mpo.SideBySide},
+		{"cross-eyed", mpo.CrossEyed},
+		{"over-under", mpo.OverUnder},
+		{"half-sbs", mpo.HalfSideBySide},
+		{"half-over-under", mpo.HalfOverUnder},
+	}
+
+	for _, tc := range layouts {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := pair.ConvertToStereoWithOptions(&mpo.StereoOptions{Layout: tc.layout})
+			if err != nil {
+				t.Fatalf("ConvertToStereoWithOptions failed: %v", err)
+			}
+
+			m, err := mpo.Split(img, &mpo.SplitOptions{Layout: tc.layout})
+			if err != nil {
+				t.Fatalf("Split failed: %v", err)
+			}
+			if len(m.Image) != 2 {
+				t.Fatalf("got %d frames, want 2", len(m.Image))
+			}
+			for i, want := range []color.RGBA{red, green} {
+				f := m.Image[i]
+				b := f.Bounds()
+				if b.Dx() != 20 || b.Dy() != 10 {
+					t.Errorf("frame %d is %v, want 20x10", i, b)
+				}
+				if got := color.RGBAModel.Convert(f.At(b.Min.X+b.Dx()/2, b.Min.Y+b.Dy()/2)); got != want {
+					t.Errorf("frame %d = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+
+	if _, err := mpo.Split(solid(4, 4, red), &mpo.SplitOptions{Layout: 99}); !errors.Is(err, mpo.ErrUnsupportedLayout) {
+		t.Errorf("unknown layout: err = %v, want ErrUnsupportedLayout", err)
+	}
+}
+
+func TestSplitGap(t *testing.T) {
+	left, right := noise(10, 6, 1), noise(10, 6, 2)
+	img := image.NewRGBA(image.Rect(0, 0, 24, 6))
+	draw.Draw(img, image.Rect(0, 0, 10, 6), left, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(14, 0, 24, 6), right, image.Point{}, draw.Src)
+
+	m, err := mpo.Split(img, &mpo.SplitOptions{Gap: 4})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	for i, want := range []*image.RGBA{left, right} {
+		assertSameImage(t, i, m.Image[i], want)
+	}
+}
+
+func TestSplitTrimGap(t *testing.T) {
+	// a scanned card: a white border, and an off-centre white divider
+	left, right := noise(30, 20, 3), noise(28, 20, 4)
+	card := solid(5+30+6+28+5, 5+20+5, color.White)
+	draw.Draw(card, image.Rect(5, 5, 35, 25), left, image.Point{}, draw.Src)
+	draw.Draw(card, image.Rect(41, 5, 69, 25), right, image.Point{}, draw.Src)
+
+	m, err := mpo.Split(card, &mpo.SplitOptions{TrimGap: true})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+
+	// the wider left frame loses columns on its outer edge
+	assertSameImage(t, 0, m.Image[0], left.SubImage(image.Rect(2, 0, 30, 20)))
+	assertSameImage(t, 1, m.Image[1], right)
+}
+
+func assertSameImage(t *testing.T, i int, got, want image.Image) {
+	t.Helper()
+	gb, wb := got.Bounds(), want.Bounds()
+	if gb.Size() != wb.Size() {
+		t.Errorf("frame %d is %v, want size %v", i, gb, wb.Size())
+		return
+	}
+	for y := range wb.Dy() {
+		for x := range wb.Dx() {
+			g := color.RGBAModel.Convert(got.At(gb.Min.X+x, gb.Min.Y+y))
+			w := color.RGBAModel.Convert(want.At(wb.Min.X+x, wb.Min.Y+y))
+			if g != w {
+				t.Errorf("frame %d pixel %d,%d = %v, want %v", i, x, y, g, w)
+				return
+			}
+		}
+	}
+}
@@ -1,32 +1,333 @@
 package mpo
 
 import (
+	"errors"
+	"fmt"
 	"image"
 	"image/draw"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// StereoLayout selects how ConvertToStereoWithOptions arranges the frames.
+type StereoLayout int
+
+const (
+	// SideBySide places the frames left to right at full size, the layout
+	// produced by ConvertToStereo, for parallel free viewing.
+	SideBySide StereoLayout = iota
+
+	// CrossEyed places the frames right to left at full size, for
+	// cross-eyed free viewing.
+	CrossEyed
+
+	// OverUnder stacks the frames top to bottom at full size.
+	OverUnder
+
+	// HalfSideBySide places the frames left to right, each squeezed
+	// horizontally so the output is as wide as a single frame, as expected
+	// by 3D TVs and headsets.
+	HalfSideBySide
+
+	// HalfOverUnder stacks the frames top to bottom, each squeezed
+	// vertically so the output is as tall as a single frame.
+	HalfOverUnder
 )
 
-// ConvertToStereo converts an MPO to a StereoScopic image
+// Mirror selects a frame to flip horizontally, as needed by mirror
+// stereoscopes.
+type Mirror int
+
+const (
+	// MirrorNone flips no frame.
+	MirrorNone Mirror = iota
+
+	// MirrorLeft flips the first frame.
+	MirrorLeft
+
+	// MirrorRight flips the last frame.
+	MirrorRight
+)
+
+// Align positions frames of different sizes across the layout: vertically
+// in side-by-side layouts, horizontally in over/under layouts, where top
+// means left and bottom means right.
+type Align int
+
+const (
+	// AlignTop aligns the top (left) edges of the frames.
+	AlignTop Align = iota
+
+	// AlignCenter centres the frames.
+	AlignCenter
+
+	// AlignBottom aligns the bottom (right) edges of the frames.
+	AlignBottom
+)
+
+// StereoOptions are the parameters used by ConvertToStereoWithOptions.
+type StereoOptions struct {
+	Layout StereoLayout
+	Mirror Mirror
+
+	// Align positions frames smaller than the largest across the layout.
+	Align Align
+
+	// ScaleToFit scales every frame, keeping its aspect ratio, to the
+	// height of the tallest frame, or to the width of the widest in
+	// over/under layouts, instead of aligning them.
+	ScaleToFit bool
+
+	// Width and Height, if non-zero, are the size of the output in pixels.
+	// If only one is given the other follows the aspect ratio of the
+	// unscaled output. Each frame is scaled, keeping its aspect ratio, to
+	// fit its share of the output and centred in it, so with both given
+	// the frames are letterboxed as needed; for the half size layouts the
+	// aspect ratio is kept as the frame will be shown, stretched back to
+	// the full output size. Align and ScaleToFit have no effect.
+	Width, Height int
+
+	// Interpolator scales the frames. If nil, draw.CatmullRom is used;
+	// draw.ApproxBiLinear is much faster.
+	Interpolator xdraw.Interpolator
+}
+
+// interpolator returns the Interpolator of o or the default.
+func (o *StereoOptions) interpolator() xdraw.Interpolator {
+	if o.Interpolator == nil {
+		return xdraw.CatmullRom
+	}
+	return o.Interpolator
+}
+
+// ErrUnsupportedLayout indicates that the stereo layout requested is not
+// supported by the stereo conversion process.
+var ErrUnsupportedLayout = errors.New("unsupported stereo layout")
+
+// ConvertToStereo converts an MPO to a StereoScopic image, placing the
+// frames of its DefaultPair side by side.
 func (m *MPO) ConvertToStereo() image.Image {
-	mx := 0
-	my := 0
-	for _, i := range m.Image {
-		mx += i.Bounds().Max.X
-		if i.Bounds().Max.Y > my {
-			my = i.Bounds().Max.Y
+	return m.viewPair().composeStereo(&StereoOptions{})
+}
+
+// ConvertToStereoWithOptions converts an MPO to a stereoscopic image with
+// the frames of its DefaultPair arranged as specified by o. A nil o behaves
+// as ConvertToStereo.
+//
+// ErrNoImages is returned if the MPO holds no frames.
+// ErrUnsupportedLayout is returned if the layout, mirror or alignment
+// requested is not supported.
+func (m *MPO) ConvertToStereoWithOptions(o *StereoOptions) (image.Image, error) {
+	if o == nil {
+		o = &StereoOptions{}
+	}
+	if o.Layout < SideBySide || o.Layout > HalfOverUnder {
+		return nil, fmt.Errorf("unsupported layout %d: %w", o.Layout, ErrUnsupportedLayout)
+	}
+	if o.Mirror < MirrorNone || o.Mirror > MirrorRight {
+		return nil, fmt.Errorf("unsupported mirror %d: %w", o.Mirror, ErrUnsupportedLayout)
+	}
+	if o.Align < AlignTop || o.Align > AlignBottom {
+		return nil, fmt.Errorf("unsupported alignment %d: %w", o.Align, ErrUnsupportedLayout)
+	}
+	if o.Width < 0 || o.Height < 0 || o.Width > MaxImageDimension || o.Height > MaxImageDimension {
+		return nil, fmt.Errorf("invalid output size %dx%d", o.Width, o.Height)
+	}
+	if len(m.Image) == 0 {
+		return nil, ErrNoImages
+	}
+
+	m = m.viewPair()
+	if o.Width > 0 || o.Height > 0 {
+		return m.composeResized(o), nil
+	}
+	return m.composeStereo(o), nil
+}
+
+// composeResized draws the frames of m onto an image of the size given by
+// o, each fitted to its share of it.
+func (m *MPO) composeResized(o *StereoOptions) *image.RGBA {
+	w, h := o.Width, o.Height
+	if w == 0 || h == 0 {
+		unscaled := *o
+		unscaled.Width, unscaled.Height = 0, 0
+		b := m.composeStereo(&unscaled).Bounds()
+		if b.Empty() {
+			return image.NewRGBA(image.Rectangle{})
 		}
+		w, h = fitSize(b.Dx(), b.Dy(), w, h)
 	}
 
-	img := image.NewRGBA(image.Rect(0, 0, mx, my))
+	frames := stereoFrames(m.Image, o)
+	n := len(frames)
+	vertical := o.Layout == OverUnder || o.Layout == HalfOverUnder
 
-	dx := 0
-	for _, i := range m.Image {
-		b := i.Bounds()
-		b = b.Add(image.Point{dx, 0})
+	// cell is each frame's share of the output, shown at the size of view
+	cellW, cellH := max(w/n, 1), h
+	if vertical {
+		cellW, cellH = w, max(h/n, 1)
+	}
+	viewW, viewH := cellW, cellH
+	switch o.Layout {
+	case HalfSideBySide:
+		viewW = w
+	case HalfOverUnder:
+		viewH = h
+	}
 
-		draw.Draw(img, b, i, image.Point{0, 0}, draw.Src)
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i, f := range frames {
+		b := f.Bounds()
+		if b.Empty() {
+			continue
+		}
+		fw, fh := fitSize(b.Dx(), b.Dy(), viewW, viewH)
+		fw, fh = max(fw*cellW/viewW, 1), max(fh*cellH/viewH, 1)
 
-		dx += i.Bounds().Max.X
+		pt := image.Pt(i*cellW+(cellW-fw)/2, (cellH-fh)/2)
+		if vertical {
+			pt = image.Pt((cellW-fw)/2, i*cellH+(cellH-fh)/2)
+		}
+		o.interpolator().Scale(img, image.Rectangle{pt, pt.Add(image.Pt(fw, fh))}, f, b, xdraw.Src, nil)
 	}
 
 	return img
 }
+
+// composeStereo draws the frames of m onto a single image as arranged by o.
+func (m *MPO) composeStereo(o *StereoOptions) *image.RGBA {
+	frames, rects, size := stereoLayout(m.Image, o)
+
+	img := image.NewRGBA(image.Rectangle{Max: size})
+	for i, f := range frames {
+		drawScaled(img, rects[i], f, o.interpolator())
+	}
+
+	n := max(len(frames), 1)
+	switch o.Layout {
+	case HalfSideBySide:
+		img = scaleWith(o.interpolator(), img, max(size.X/n, 1), size.Y)
+	case HalfOverUnder:
+		img = scaleWith(o.interpolator(), img, size.X, max(size.Y/n, 1))
+	}
+
+	return img
+}
+
+// stereoLayout arranges frames as specified by o, at full size for the half
+// layouts. It returns the frames with the mirroring of o applied, in the
+// order they are drawn, the rectangle each is drawn into, scaled if
+// ScaleToFit requires, and the size of the whole layout.
+func stereoLayout(images []image.Image, o *StereoOptions) (frames []image.Image, rects []image.Rectangle, size image.Point) {
+	frames = stereoFrames(images, o)
+
+	vertical := o.Layout == OverUnder || o.Layout == HalfOverUnder
+
+	// sizes hold the size of each frame along the direction frames are
+	// placed as X, and across it as Y
+	sizes := make([]image.Point, len(frames))
+	maxAcross := 0
+	for i, f := range frames {
+		b := f.Bounds()
+		sizes[i] = image.Pt(b.Dx(), b.Dy())
+		if vertical {
+			sizes[i] = image.Pt(b.Dy(), b.Dx())
+		}
+		maxAcross = max(maxAcross, sizes[i].Y)
+	}
+
+	if o.ScaleToFit {
+		for i, sz := range sizes {
+			if sz.Y != maxAcross && sz.Y > 0 {
+				sizes[i] = image.Pt(sz.X*maxAcross/sz.Y, maxAcross)
+			}
+		}
+	}
+
+	rects = make([]image.Rectangle, len(frames))
+	d := 0
+	for i, sz := range sizes {
+		var off int
+		switch o.Align {
+		case AlignCenter:
+			off = (maxAcross - sz.Y) / 2
+		case AlignBottom:
+			off = maxAcross - sz.Y
+		}
+
+		r := image.Rect(d, off, d+sz.X, off+sz.Y)
+		if vertical {
+			r = image.Rect(off, d, off+sz.Y, d+sz.X)
+		}
+		rects[i] = r
+		d += sz.X
+	}
+
+	size = image.Pt(d, maxAcross)
+	if vertical {
+		size = image.Pt(maxAcross, d)
+	}
+
+	return frames, rects, size
+}
+
+// drawScaled draws img into r of dst, scaling it by interp if it is not the
+// size of r.
+func drawScaled(dst draw.Image, r image.Rectangle, img image.Image, interp xdraw.Interpolator) {
+	b := img.Bounds()
+	if b.Size() == r.Size() {
+		draw.Draw(dst, r, img, b.Min, draw.Src)
+		return
+	}
+	interp.Scale(dst, r, img, b, xdraw.Src, nil)
+}
+
+// stereoFrames returns a copy of frames with the mirroring of o applied, in
+// the order the layout of o draws them.
+func stereoFrames(frames []image.Image, o *StereoOptions) []image.Image {
+	out := make([]image.Image, len(frames))
+	copy(out, frames)
+
+	if len(out) > 0 {
+		switch o.Mirror {
+		case MirrorLeft:
+			out[0] = flipHorizontal(out[0])
+		case MirrorRight:
+			out[len(out)-1] = flipHorizontal(out[len(out)-1])
+		}
+	}
+
+	if o.Layout == CrossEyed {
+		for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+			out[i], out[j] = out[j], out[i]
+		}
+	}
+
+	return out
+}
+
+// scale scales img to width×height, ignoring its aspect ratio.
+func scale(img image.Image, width, height int) *image.RGBA {
+	return scaleWith(xdraw.CatmullRom, img, width, height)
+}
+
+// scaleWith returns img scaled to width×height by interp.
+func scaleWith(interp xdraw.Interpolator, img image.Image, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	interp.Scale(dst, dst.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+
+	return dst
+}
+
+// flipHorizontal returns a mirror image of img.
+func flipHorizontal(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(b.Max.X-1-(x-b.Min.X), y, img.At(x, y))
+		}
+	}
+
+	return dst
+}
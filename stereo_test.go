@@ -1,6 +1,7 @@
 package mpo_test
 
 import (
+	"errors"
 	"image"
 	"image/color"
 	"testing"
@@ -95,3 +96,152 @@ func TestConvertToAnaglyph_LuminanceCoefficients(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertToStereoWithOptions(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	green := color.RGBA{0, 255, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	white := color.RGBA{255, 255, 255, 255}
+
+	// Left frame is red|green, right frame is blue|white.
+	left := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	left.Set(0, 0, red)
+	left.Set(1, 0, green)
+	right := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	right.Set(0, 0, blue)
+	right.Set(1, 0, white)
+
+	m := &mpo.MPO{Image: []image.Image{left, right}}
+
+	tests := []struct {
+		name   string
+		opts   mpo.StereoOptions
+		w, h   int
+		pixels map[image.Point]color.RGBA
+	}{
+		{"side-by-side", mpo.StereoOptions{Layout: mpo.SideBySide}, 4, 1,
+			map[image.Point]color.RGBA{{0, 0}: red, {1, 0}: green, {2, 0}: blue, {3, 0}: white}},
+		{"cross-eyed", mpo.StereoOptions{Layout: mpo.CrossEyed}, 4, 1,
+			map[image.Point]color.RGBA{{0, 0}: blue, {1, 0}: white, {2, 0}: red, {3, 0}: green}},
+		{"over-under", mpo.StereoOptions{Layout: mpo.OverUnder}, 2, 2,
+			map[image.Point]color.RGBA{{0, 0}: red, {1, 0}: green, {0, 1}: blue, {1, 1}: white}},
+		{"half side-by-side", mpo.StereoOptions{Layout: mpo.HalfSideBySide}, 2, 1, nil},
+		{"half over-under", mpo.StereoOptions{Layout: mpo.HalfOverUnder}, 2, 1, nil},
+		{"mirror left", mpo.StereoOptions{Mirror: mpo.MirrorLeft}, 4, 1,
+			map[image.Point]color.RGBA{{0, 0}: green, {1, 0}: red, {2, 0}: blue, {3, 0}: white}},
+		{"mirror right", mpo.StereoOptions{Mirror: mpo.MirrorRight}, 4, 1,
+			map[image.Point]color.RGBA{{0, 0}: red, {1, 0}: green, {2, 0}: white, {3, 0}: blue}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := m.ConvertToStereoWithOptions(&tc.opts)
+			if err != nil {
+				t.Fatalf("ConvertToStereoWithOptions failed: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != tc.w || b.Dy() != tc.h {
+				t.Fatalf("bounds = %dx%d, want %dx%d", b.Dx(), b.Dy(), tc.w, tc.h)
+			}
+			for p, want := range tc.pixels {
+				if got := color.RGBAModel.Convert(img.At(p.X, p.Y)); got != want {
+					t.Errorf("pixel %v = %v, want %v", p, got, want)
+				}
+			}
+		})
+	}
+
+	if _, err := m.ConvertToStereoWithOptions(&mpo.StereoOptions{Layout: 99}); !errors.Is(err, mpo.ErrUnsupportedLayout) {
+		t.Errorf("unsupported layout error = %v, want ErrUnsupportedLayout", err)
+	}
+
+	// Of three views, only the default pair from the base viewpoint is used.
+	multi := &mpo.MPO{Image: []image.Image{solid(2, 1, red), left, right}, BaseViewpoint: 2}
+	img, err := multi.ConvertToStereoWithOptions(nil)
+	if err != nil {
+		t.Fatalf("ConvertToStereoWithOptions failed: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 4 || b.Dy() != 1 {
+		t.Fatalf("multi-view bounds = %dx%d, want 4x1", b.Dx(), b.Dy())
+	}
+	if got := color.RGBAModel.Convert(img.At(1, 0)); got != green {
+		t.Errorf("multi-view pixel 1,0 = %v, want %v", got, green)
+	}
+}
+
+func TestConvertToStereoWithOptions_Bounds(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+
+	fill := func(r image.Rectangle, c color.RGBA) *image.RGBA {
+		img := image.NewRGBA(r)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				img.Set(x, y, c)
+			}
+		}
+		return img
+	}
+
+	t.Run("sub-images", func(t *testing.T) {
+		// Frames cut from the middle of larger images, bounds far from the origin.
+		left := fill(image.Rect(0, 0, 10, 10), red).SubImage(image.Rect(4, 6, 6, 8))
+		right := fill(image.Rect(0, 0, 10, 10), blue).SubImage(image.Rect(7, 2, 9, 4))
+
+		m := &mpo.MPO{Image: []image.Image{left, right}}
+		img := m.ConvertToStereo()
+		if b := img.Bounds(); b != image.Rect(0, 0, 4, 2) {
+			t.Fatalf("bounds = %v, want %v", b, image.Rect(0, 0, 4, 2))
+		}
+		for x := range 4 {
+			want := red
+			if x >= 2 {
+				want = blue
+			}
+			for y := range 2 {
+				if got := color.RGBAModel.Convert(img.At(x, y)); got != want {
+					t.Errorf("pixel %d,%d = %v, want %v", x, y, got, want)
+				}
+			}
+		}
+	})
+
+	// A 2×4 left frame next to a 2×2 right frame.
+	m := &mpo.MPO{Image: []image.Image{
+		fill(image.Rect(0, 0, 2, 4), red),
+		fill(image.Rect(0, 0, 2, 2), blue),
+	}}
+
+	tests := []struct {
+		name  string
+		opts  mpo.StereoOptions
+		w, h  int
+		blueY []int // rows of column 2 that are blue
+	}{
+		{"top", mpo.StereoOptions{Align: mpo.AlignTop}, 4, 4, []int{0, 1}},
+		{"center", mpo.StereoOptions{Align: mpo.AlignCenter}, 4, 4, []int{1, 2}},
+		{"bottom", mpo.StereoOptions{Align: mpo.AlignBottom}, 4, 4, []int{2, 3}},
+		{"scale to fit", mpo.StereoOptions{ScaleToFit: true}, 6, 4, []int{0, 1, 2, 3}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := m.ConvertToStereoWithOptions(&tc.opts)
+			if err != nil {
+				t.Fatalf("ConvertToStereoWithOptions failed: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != tc.w || b.Dy() != tc.h {
+				t.Fatalf("bounds = %dx%d, want %dx%d", b.Dx(), b.Dy(), tc.w, tc.h)
+			}
+			isBlue := make(map[int]bool)
+			for _, y := range tc.blueY {
+				isBlue[y] = true
+			}
+			for y := range tc.h {
+				_, _, b, a := img.At(2, y).RGBA()
+				if got := b > 0x8000 && a > 0; got != isBlue[y] {
+					t.Errorf("pixel 2,%d blue = %v, want %v", y, got, isBlue[y])
+				}
+			}
+		})
+	}
+}
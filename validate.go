@@ -0,0 +1,362 @@
+package mpo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Severity classifies an Issue reported by Validate.
+type Severity int
+
+const (
+	// SeverityInfo notes something optional that some viewers rely on.
+	SeverityInfo Severity = iota
+
+	// SeverityWarning is a deviation from CIPA DC‑007 that most readers
+	// tolerate.
+	SeverityWarning
+
+	// SeverityError is a violation of CIPA DC‑007 that is likely to stop
+	// hardware viewers loading the file.
+	SeverityError
+)
+
+// String returns the lowercase name of s.
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Issue is a single conformance problem found by Validate.
+type Issue struct {
+	Severity Severity `json:"severity"`
+
+	// Image is the 0-based MP Entry the issue concerns, or -1 for the file
+	// as a whole.
+	Image int `json:"image"`
+
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	if i.Image < 0 {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: image %d: %s", i.Severity, i.Image, i.Message)
+}
+
+// Validate checks the MPO read from r against CIPA DC‑007 and returns every
+// problem found, most of which EncodeAll and DecodeAll would not notice. It
+// covers the MPF version, image count, MP Entry offsets, sizes and flags,
+// Dependent Image entries, MP Attribute IFD presence, baseline JPEG coding of
+// Baseline MP images and the order of the APP segments.
+//
+// The returned error is only non-nil if r could not be read; a file that is
+// not an MPO at all is reported as issues.
+func Validate(r io.Reader) ([]Issue, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &validator{data: data, r: bytes.NewReader(data)}
+	v.run()
+
+	return v.issues, nil
+}
+
+// Individual image data format bits of the MP Entry attribute; 0 is JPEG.
+const mpFormatMask = 0x07000000
+
+// MP types defined by CIPA DC‑007 Table 4.
+var mpTypeNames = map[uint32]string{
+	mpTypeLargeThumbVGA:    "Large Thumbnail (VGA)",
+	mpTypeLargeThumbFullHD: "Large Thumbnail (Full-HD)",
+	mpTypePanorama:         "Multi-Frame Image (Panorama)",
+	mpTypeDisparity:        "Multi-Frame Image (Disparity)",
+	mpTypeMultiAngle:       "Multi-Frame Image (Multi-Angle)",
+	mpTypeBaseline:         "Baseline MP Primary Image",
+}
+
+type validator struct {
+	data   []byte
+	r      *bytes.Reader
+	issues []Issue
+}
+
+func (v *validator) add(sev Severity, img int, format string, args ...any) {
+	v.issues = append(v.issues, Issue{Severity: sev, Image: img, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) run() {
+	segs, err := readSegments(v.r, 0)
+	if err != nil {
+		v.add(SeverityError, -1, "cannot read first JPEG: %v", err)
+		return
+	}
+
+	seg, ok := findMPF(segs)
+	if !ok {
+		v.add(SeverityError, -1, "first image has no APP2/MPF segment")
+		return
+	}
+	v.checkSegmentOrder(0, segs)
+
+	mpf, err := parseMPF(seg.data)
+	if err != nil {
+		v.add(SeverityError, -1, "invalid APP2/MPF segment: %v", err)
+		return
+	}
+	if mpf.index == nil {
+		v.add(SeverityError, -1, "first image has no MP Index IFD")
+		return
+	}
+
+	v.checkVersion(-1, mpf, mpf.index, "MP Index IFD")
+
+	n, ok := mpf.long(mpf.index, tagNumImages)
+	if !ok {
+		v.add(SeverityError, -1, "MP Index IFD has no NumberOfImages")
+	} else if int(n) != len(mpf.entries) {
+		v.add(SeverityError, -1, "NumberOfImages is %d but the MP Image List has %d entries", n, len(mpf.entries))
+	}
+	if _, ok := mpf.index[tagMPImageList]; !ok {
+		v.add(SeverityError, -1, "MP Index IFD has no MP Image List")
+		return
+	}
+	if len(mpf.entries) < 2 {
+		v.add(SeverityWarning, -1, "MP Image List has %d entries, an MP file holds at least 2", len(mpf.entries))
+	}
+	if len(mpf.entries) == 0 {
+		return
+	}
+
+	v.checkEntries(seg.offset+8, mpf, segs)
+}
+
+// checkEntries checks every MP Entry and the image it points at, endian being
+// the file position offsets are measured from.
+func (v *validator) checkEntries(endian int64, first *mpfData, firstSegs []jpegSegment) {
+	entries := first.entries
+
+	var representatives, primaries int
+	parents := make(map[int]bool)
+	type span struct{ start, end int64 }
+	spans := make([]span, len(entries))
+
+	for i, e := range entries {
+		typ := e.attr & mpTypeMask
+		name, known := mpTypeNames[typ]
+		if !known {
+			v.add(SeverityWarning, i, "unknown MP type 0x%06X", typ)
+		}
+		if typ == mpTypeBaseline {
+			primaries++
+		}
+		if e.attr&flagRepresentative != 0 {
+			representatives++
+			if isLargeThumbnail(e) {
+				v.add(SeverityError, i, "%s is flagged as the representative image", name)
+			}
+		}
+		if f := e.attr & mpFormatMask; f != 0 {
+			v.add(SeverityError, i, "image data format %d is not JPEG", f>>24)
+		}
+
+		// ── location ─────────────────────────────────────────────────────────
+		var start int64
+		switch {
+		case i == 0 && e.offset != 0:
+			v.add(SeverityError, i, "first image offset is %d, must be 0", e.offset)
+			continue
+		case i > 0 && e.offset == 0:
+			v.add(SeverityError, i, "offset is 0, only the first image may have offset 0")
+			continue
+		case i > 0:
+			start = endian + int64(e.offset)
+		}
+		end := start + int64(e.size)
+		if e.size < 4 || end > int64(len(v.data)) {
+			v.add(SeverityError, i, "image at %d with size %d extends beyond the %d byte file", start, e.size, len(v.data))
+			continue
+		}
+		if v.data[start] != mpojpgMKR || v.data[start+1] != mpojpgSOI {
+			v.add(SeverityError, i, "offset %d does not point at an SOI marker", e.offset)
+			continue
+		}
+		if v.data[end-2] != mpojpgMKR || v.data[end-1] != mpojpgEOI {
+			v.add(SeverityError, i, "size %d does not end at an EOI marker", e.size)
+		}
+		spans[i] = span{start, end}
+		for j := range i {
+			if spans[j].end > start && spans[j].start < end {
+				v.add(SeverityError, i, "overlaps image %d", j)
+			}
+		}
+
+		// ── image content ────────────────────────────────────────────────────
+		segs := firstSegs
+		if i > 0 {
+			var err error
+			if segs, err = readSegments(v.r, start); err != nil {
+				v.add(SeverityError, i, "cannot read JPEG: %v", err)
+				continue
+			}
+			v.checkSegmentOrder(i, segs)
+		}
+		v.checkAttributes(i, e, first, segs)
+		v.checkCoding(i, e, segs)
+
+		// ── dependent images ─────────────────────────────────────────────────
+		for _, dep := range []uint16{e.dep1, e.dep2} {
+			switch {
+			case dep == 0:
+			case int(dep) > len(entries):
+				v.add(SeverityError, i, "Dependent Image entry %d does not exist", dep)
+			case int(dep) == i+1:
+				v.add(SeverityError, i, "Dependent Image entry refers to itself")
+			case entries[dep-1].attr&flagDependentChild == 0:
+				v.add(SeverityWarning, i, "Dependent Image entry %d is not flagged as a dependent child", dep)
+			default:
+				parents[int(dep)-1] = true
+			}
+		}
+		hasDeps := e.dep1 != 0 || e.dep2 != 0
+		if hasDeps != (e.attr&flagDependentParent != 0) {
+			v.add(SeverityWarning, i, "dependent parent flag does not match its Dependent Image entries")
+		}
+	}
+
+	for i, e := range entries {
+		if e.attr&flagDependentChild != 0 && !parents[i] {
+			v.add(SeverityWarning, i, "flagged as a dependent child but no image lists it as a dependent")
+		}
+		if isLargeThumbnail(e) && e.attr&flagDependentChild == 0 {
+			v.add(SeverityWarning, i, "Large Thumbnail is not flagged as a dependent child")
+		}
+	}
+
+	switch {
+	case representatives == 0:
+		v.add(SeverityError, -1, "no image is flagged as the representative image")
+	case representatives > 1:
+		v.add(SeverityError, -1, "%d images are flagged as the representative image, only one may be", representatives)
+	}
+	if primaries > 1 {
+		v.add(SeverityWarning, -1, "%d images are Baseline MP Primary Images, a Baseline MP file has one", primaries)
+	}
+}
+
+// checkAttributes checks the MP Attribute IFD of image i.
+func (v *validator) checkAttributes(i int, e mpEntry, first *mpfData, segs []jpegSegment) {
+	mpf := first
+	if i > 0 {
+		seg, ok := findMPF(segs)
+		if !ok {
+			mpf = nil
+		} else {
+			var err error
+			if mpf, err = parseMPF(seg.data); err != nil {
+				v.add(SeverityError, i, "invalid APP2/MPF segment: %v", err)
+				return
+			}
+		}
+	}
+
+	if mpf == nil || mpf.attr == nil {
+		typ := e.attr & mpTypeMask
+		if typ == mpTypePanorama || typ == mpTypeDisparity || typ == mpTypeMultiAngle {
+			v.add(SeverityError, i, "Multi-Frame Image has no MP Attribute IFD")
+		} else {
+			v.add(SeverityInfo, i, "no MP Attribute IFD")
+		}
+		return
+	}
+
+	v.checkVersion(i, mpf, mpf.attr, "MP Attribute IFD")
+}
+
+// checkVersion checks the MPFVersion field of ifd.
+func (v *validator) checkVersion(i int, mpf *mpfData, ifd map[uint16]ifdEntry, name string) {
+	ver, ok := ifd[tagMPFVersion]
+	switch {
+	case !ok:
+		v.add(SeverityError, i, "%s has no MPFVersion", name)
+	case ver.typ != typeUNDEFINED || ver.count != 4:
+		v.add(SeverityError, i, "%s MPFVersion has type %d count %d, want UNDEFINED[4]", name, ver.typ, ver.count)
+	case string(ver.value) != "0100":
+		v.add(SeverityWarning, i, "%s MPFVersion is %q, want \"0100\"", name, ver.value)
+	}
+}
+
+// checkCoding checks that Baseline MP images are baseline JPEGs and Large
+// Thumbnails fit their class.
+func (v *validator) checkCoding(i int, e mpEntry, segs []jpegSegment) {
+	var sof *jpegSegment
+	for j, s := range segs {
+		if s.marker >= 0xC0 && s.marker <= 0xCF && s.marker != 0xC4 && s.marker != 0xC8 && s.marker != 0xCC {
+			sof = &segs[j]
+			break
+		}
+	}
+	if sof == nil || len(sof.data) < 5 {
+		v.add(SeverityError, i, "no frame header before the first scan")
+		return
+	}
+
+	typ := e.attr & mpTypeMask
+	if (typ == mpTypeBaseline || isLargeThumbnail(e)) && sof.marker != 0xC0 {
+		v.add(SeverityError, i, "%s must be baseline JPEG, found SOF%d", mpTypeNames[typ], sof.marker-0xC0)
+	}
+
+	h := int(sof.data[1])<<8 | int(sof.data[2])
+	w := int(sof.data[3])<<8 | int(sof.data[4])
+	for _, t := range largeThumbnails {
+		if typ == t.mpType && (w > t.width || h > t.height) {
+			v.add(SeverityWarning, i, "%s is %dx%d, larger than %dx%d", mpTypeNames[typ], w, h, t.width, t.height)
+		}
+	}
+}
+
+// checkSegmentOrder checks that the APP2/MPF segment of image i follows any
+// APP1/Exif segment and precedes the JPEG tables.
+func (v *validator) checkSegmentOrder(i int, segs []jpegSegment) {
+	mpfAt, exifAt := -1, -1
+	for j, s := range segs {
+		if mpfAt < 0 && s.marker == mpojpgAPP2 && bytes.HasPrefix(s.data, []byte("MPF\x00")) {
+			mpfAt = j
+		}
+		if exifAt < 0 && s.marker == mpojpgAPP1 && bytes.HasPrefix(s.data, []byte("Exif\x00")) {
+			exifAt = j
+		}
+	}
+
+	if exifAt < 0 {
+		v.add(SeverityInfo, i, "no APP1/Exif segment, which DCF viewers may require")
+	}
+	if mpfAt < 0 {
+		return
+	}
+	if exifAt > mpfAt {
+		v.add(SeverityWarning, i, "APP1/Exif segment follows the APP2/MPF segment")
+	}
+	for _, s := range segs[:mpfAt] {
+		if (s.marker < 0xE0 || s.marker > 0xEF) && s.marker != 0xFE { // APPn or COM
+			v.add(SeverityError, i, "APP2/MPF segment follows the JPEG tables")
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package mpo_test
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"strings"
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+func TestValidate_EncodeAll(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 800, 600))
+
+	tests := []struct {
+		name string
+		m    *mpo.MPO
+		opts *mpo.EncodeOptions
+	}{
+		{"stereo pair", &mpo.MPO{Image: []image.Image{img, img}}, nil},
+		{"multi-angle", &mpo.MPO{Image: []image.Image{img, img, img}}, nil},
+		{"thumbnails", &mpo.MPO{Image: []image.Image{img, img}}, &mpo.EncodeOptions{Representative: 1, LargeThumbnails: mpo.ThumbnailVGA}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := mpo.EncodeAllWithOptions(&buf, tc.m, tc.opts); err != nil {
+				t.Fatalf("EncodeAllWithOptions failed: %v", err)
+			}
+
+			issues, err := mpo.Validate(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("Validate failed: %v", err)
+			}
+			// only the informational note that there is no Exif segment
+			for _, is := range issues {
+				if is.Severity >= mpo.SeverityWarning {
+					t.Errorf("unexpected issue: %s", is)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_Errors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+
+	var plain bytes.Buffer
+	if err := jpeg.Encode(&plain, img, nil); err != nil {
+		t.Fatalf("jpeg.Encode failed: %v", err)
+	}
+
+	var good bytes.Buffer
+	if err := mpo.EncodeAll(&good, &mpo.MPO{Image: []image.Image{img, img}}, nil); err != nil {
+		t.Fatalf("EncodeAll failed: %v", err)
+	}
+
+	// Clear the representative flag of the first MP Entry.
+	noRepr := bytes.Clone(good.Bytes())
+	i := bytes.Index(noRepr, []byte{0x00, 0x00, 0x03, 0x20})
+	if i < 0 {
+		t.Fatal("representative MP Entry not found")
+	}
+	noRepr[i+3] = 0x00
+
+	// Truncate the second frame.
+	truncated := good.Bytes()[:good.Len()-100]
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"not an mpo", []byte("GIF89a"), "cannot read first JPEG"},
+		{"plain jpeg", plain.Bytes(), "no APP2/MPF segment"},
+		{"no representative", noRepr, "no image is flagged as the representative image"},
+		{"truncated", truncated, "extends beyond"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			issues, err := mpo.Validate(bytes.NewReader(tc.data))
+			if err != nil {
+				t.Fatalf("Validate failed: %v", err)
+			}
+			for _, is := range issues {
+				if is.Severity == mpo.SeverityError && strings.Contains(is.Message, tc.want) {
+					return
+				}
+			}
+			t.Errorf("no error containing %q in %v", tc.want, issues)
+		})
+	}
+}
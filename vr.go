@@ -0,0 +1,160 @@
+package mpo
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// VRViewer describes the lenses of a phone-based VR viewer. Lengths are in
+// millimetres.
+//
+// The lenses magnify the screen with pincushion distortion: a point on the
+// screen at a distance r from a lens centre, measured as the tangent of the
+// angle it makes with the lens axis, r = distance / ScreenDistance, is seen
+// at r·(1 + K1·r² + K2·r⁴). ConvertToVR applies the opposite, barrel,
+// distortion so the picture appears undistorted.
+type VRViewer struct {
+	// K1 and K2 are the radial distortion coefficients of the lenses.
+	K1, K2 float64
+
+	// LensSeparation is the distance between the centres of the lenses,
+	// which should be close to the viewer's interpupillary distance.
+	LensSeparation float64
+
+	// ScreenDistance is the distance from the lenses to the screen.
+	ScreenDistance float64
+}
+
+// CardboardV1 is the original 2014 Google Cardboard viewer.
+var CardboardV1 = VRViewer{
+	K1:             0.441,
+	K2:             0.156,
+	LensSeparation: 60,
+	ScreenDistance: 42,
+}
+
+// CardboardV2 is the 2015 Google Cardboard viewer, with wider field of view
+// lenses, and the default of ConvertToVR.
+var CardboardV2 = VRViewer{
+	K1:             0.34,
+	K2:             0.55,
+	LensSeparation: 64,
+	ScreenDistance: 39.3,
+}
+
+// DaydreamView is the 2016 Google Daydream View headset.
+var DaydreamView = VRViewer{
+	K1:             0.385,
+	K2:             0.593,
+	LensSeparation: 63.9,
+	ScreenDistance: 39.3,
+}
+
+// VROptions are the parameters used by ConvertToVR.
+type VROptions struct {
+	// Viewer is the viewer the image is shown in. If zero, CardboardV2 is
+	// used.
+	Viewer VRViewer
+
+	// Width and Height are the resolution of the phone's screen in
+	// landscape orientation, the size of the output. If both are zero,
+	// 1920×1080 is used.
+	Width, Height int
+
+	// ScreenSize is the diagonal of the phone's screen in inches. If zero,
+	// 5.5 is used. Pixels are assumed to be square.
+	ScreenSize float64
+
+	// FieldOfView is the horizontal angle in degrees each frame fills as
+	// seen through the lenses. If zero, 60 is used.
+	FieldOfView float64
+}
+
+// ConvertToVR renders the DefaultPair of m side by side for a phone-based VR
+// viewer as specified by o: each frame is centred on its lens, scaled to the
+// field of view and pre-distorted to cancel the distortion of the lens.
+// Areas of the screen outside a frame are black. A nil o uses the defaults.
+//
+// ErrInvalidImageCount is returned if the MPO has fewer than 2 images.
+func (m *MPO) ConvertToVR(o *VROptions) (*image.RGBA, error) {
+	if o == nil {
+		o = &VROptions{}
+	}
+	if len(m.Image) < 2 {
+		return nil, ErrInvalidImageCount
+	}
+
+	v := o.Viewer
+	if v == (VRViewer{}) {
+		v = CardboardV2
+	}
+	if v.LensSeparation <= 0 || v.ScreenDistance <= 0 {
+		return nil, fmt.Errorf("invalid viewer lens separation %g and screen distance %g", v.LensSeparation, v.ScreenDistance)
+	}
+
+	w, h := o.Width, o.Height
+	if w == 0 && h == 0 {
+		w, h = 1920, 1080
+	}
+	if w < 2 || h < 1 || w > MaxImageDimension || h > MaxImageDimension {
+		return nil, fmt.Errorf("invalid screen resolution %dx%d", w, h)
+	}
+
+	size := o.ScreenSize
+	if size == 0 {
+		size = 5.5
+	}
+	fov := o.FieldOfView
+	if fov == 0 {
+		fov = 60
+	}
+	if size < 0 || fov <= 0 || fov >= 180 {
+		return nil, fmt.Errorf("invalid screen size %g or field of view %g", size, fov)
+	}
+
+	// pixels per millimetre and the tangent of half the field of view
+	ppmm := math.Hypot(float64(w), float64(h)) / (size * 25.4)
+	tanHalf := math.Tan(fov / 2 * math.Pi / 180)
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	l, r := m.DefaultPair()
+	for eye, i := range []int{l, r} {
+		src := toRGBA(m.Image[i])
+		b := src.Bounds()
+		if b.Empty() {
+			continue
+		}
+
+		// lens centre on the screen, and the source pixels per tangent
+		lx := float64(w)/2 + float64(2*eye-1)*v.LensSeparation/2*ppmm
+		ly := float64(h) / 2
+		k := float64(b.Dx()) / (2 * tanHalf)
+		cx := float64(b.Min.X) + float64(b.Dx())/2
+		cy := float64(b.Min.Y) + float64(b.Dy())/2
+
+		for y := range h {
+			for x := eye * w / 2; x < (eye+1)*w/2; x++ {
+				// tangents of the screen point and of where it is seen
+				tx := (float64(x) + 0.5 - lx) / ppmm / v.ScreenDistance
+				ty := (float64(y) + 0.5 - ly) / ppmm / v.ScreenDistance
+				r2 := tx*tx + ty*ty
+				d := 1 + v.K1*r2 + v.K2*r2*r2
+
+				sx, sy := cx+tx*d*k, cy+ty*d*k
+				if sx < float64(b.Min.X) || sx >= float64(b.Max.X) || sy < float64(b.Min.Y) || sy >= float64(b.Max.Y) {
+					continue
+				}
+
+				p := img.PixOffset(x, y)
+				bilinear(img.Pix[p:p+4], src, sx-0.5, sy-0.5)
+			}
+		}
+	}
+
+	return img, nil
+}
@@ -0,0 +1,65 @@
+package mpo_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/donatj/mpo"
+)
+
+func TestConvertToVR(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	black := color.RGBA{0, 0, 0, 255}
+	m := &mpo.MPO{Image: []image.Image{solid(400, 300, red), solid(400, 300, blue)}}
+
+	img, err := m.ConvertToVR(nil)
+	if err != nil {
+		t.Fatalf("ConvertToVR failed: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 1920 || b.Dy() != 1080 {
+		t.Fatalf("VR image is %v, want 1920x1080", b)
+	}
+
+	// On a 5.5" screen the Cardboard v2 lenses, 64 mm apart, sit 505 pixels
+	// either side of the centre. A 60° frame would end 357 pixels from the
+	// lens centre undistorted, but the barrel distortion pulls it in to
+	// about 316.
+	tests := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{455, 540, red},
+		{1465, 540, blue},
+		{755, 540, red},
+		{795, 540, black},
+		{1165, 540, blue},
+		{1125, 540, black},
+		{0, 0, black},
+		{455, 300, red},
+		{455, 200, black},
+	}
+	for _, tc := range tests {
+		if got := img.RGBAAt(tc.x, tc.y); got != tc.want {
+			t.Errorf("pixel %d,%d = %v, want %v", tc.x, tc.y, got, tc.want)
+		}
+	}
+
+	// the narrower Cardboard v1 lenses move the frames towards the centre
+	img, err = m.ConvertToVR(&mpo.VROptions{Viewer: mpo.CardboardV1, Width: 1280, Height: 720, ScreenSize: 5})
+	if err != nil {
+		t.Fatalf("ConvertToVR failed: %v", err)
+	}
+	if got := img.RGBAAt(640-330, 360); got != red {
+		t.Errorf("Cardboard v1 left lens centre = %v, want %v", got, red)
+	}
+
+	one := &mpo.MPO{Image: []image.Image{solid(4, 4, red)}}
+	if _, err := one.ConvertToVR(nil); !errors.Is(err, mpo.ErrInvalidImageCount) {
+		t.Errorf("ConvertToVR of one frame = %v, want ErrInvalidImageCount", err)
+	}
+	if _, err := m.ConvertToVR(&mpo.VROptions{FieldOfView: 180}); err == nil {
+		t.Error("expected error for a 180° field of view")
+	}
+}
@@ -0,0 +1,235 @@
+package mpo
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"sort"
+	"time"
+)
+
+// WiggleOptions are the parameters used by ConvertToWiggle.
+type WiggleOptions struct {
+	// Delay is how long each frame is shown. Zero means 150ms. GIF delays
+	// are recorded in hundredths of a second.
+	Delay time.Duration
+
+	// PingPong plays the frames forwards then backwards, 0 1 2 1 0 1 2 …,
+	// rather than jumping from the last frame back to the first. It makes
+	// no difference to a stereo pair.
+	PingPong bool
+
+	// Align rectifies the DefaultPair of m with Align and shifts it with
+	// AutoParallax so the median depth stays still, making the scene pivot
+	// around it. Only that pair is animated, even with AllViews.
+	Align bool
+
+	// AllViews animates every frame of a multi-frame MPO, in viewpoint
+	// order, rather than its DefaultPair.
+	AllViews bool
+
+	// Width and Height, if non-zero, are the size the frames are scaled
+	// down to fit within, keeping their aspect ratio.
+	Width, Height int
+
+	// Colors is the size of the palette shared by every frame, chosen by
+	// median cut from the colours of all of them. Zero means 256.
+	Colors int
+
+	// Dither applies Floyd-Steinberg error diffusion when reducing the
+	// frames to the palette.
+	Dither bool
+}
+
+// ConvertToWiggle converts the DefaultPair of m, or every frame with
+// o.AllViews, into an animated "wiggle" GIF that loops through them, giving
+// an impression of depth without glasses. The result can be written with
+// gif.EncodeAll.
+//
+// ErrNoImages is returned if m has no frames and ErrInconsistentBounds if
+// they are not all the same size.
+func (m *MPO) ConvertToWiggle(o *WiggleOptions) (*gif.GIF, error) {
+	if o == nil {
+		o = &WiggleOptions{}
+	}
+	if len(m.Image) == 0 {
+		return nil, ErrNoImages
+	}
+
+	src := m
+	if !o.AllViews {
+		src = m.viewPair()
+	}
+	if o.Align {
+		aligned, _, err := m.Align()
+		if err != nil {
+			return nil, err
+		}
+		if src, _, err = aligned.AutoParallax(50); err != nil {
+			return nil, err
+		}
+	}
+
+	size := src.Image[0].Bounds().Size()
+	frames := make([]image.Image, len(src.Image))
+	for i, img := range src.Image {
+		if img.Bounds().Size() != size {
+			return nil, ErrInconsistentBounds
+		}
+		frames[i] = img
+		if o.Width > 0 || o.Height > 0 {
+			w, h := o.Width, o.Height
+			if w <= 0 {
+				w = MaxImageDimension
+			}
+			if h <= 0 {
+				h = MaxImageDimension
+			}
+			if scaled := scaleToFit(img, w, h); scaled != nil {
+				frames[i] = scaled
+			}
+		}
+	}
+
+	colors := o.Colors
+	if colors <= 0 || colors > 256 {
+		colors = 256
+	}
+	pal := medianCut(frames, colors)
+
+	delay := o.Delay
+	if delay <= 0 {
+		delay = 150 * time.Millisecond
+	}
+	cs := max(int(delay/(10*time.Millisecond)), 1)
+
+	order := make([]int, len(frames))
+	for i := range order {
+		order[i] = i
+	}
+	if o.PingPong {
+		for i := len(frames) - 2; i > 0; i-- {
+			order = append(order, i)
+		}
+	}
+
+	b := frames[0].Bounds()
+	paletted := make([]*image.Paletted, len(frames))
+	for i, img := range frames {
+		p := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), pal)
+		if o.Dither {
+			draw.FloydSteinberg.Draw(p, p.Bounds(), img, img.Bounds().Min)
+		} else {
+			quantize(p, img, pal)
+		}
+		paletted[i] = p
+	}
+
+	g := &gif.GIF{
+		Config: image.Config{ColorModel: pal, Width: b.Dx(), Height: b.Dy()},
+	}
+	for _, i := range order {
+		g.Image = append(g.Image, paletted[i])
+		g.Delay = append(g.Delay, cs)
+		g.Disposal = append(g.Disposal, gif.DisposalNone)
+	}
+
+	return g, nil
+}
+
+const medianCutSamples = 1 << 16 // pixels sampled from each frame
+
+// medianCut returns a palette of at most n colours for imgs, found by
+// repeatedly splitting the box of sampled colours with the widest channel
+// range at its median, and averaging each box.
+func medianCut(imgs []image.Image, n int) color.Palette {
+	var samples [][3]uint8
+	for _, img := range imgs {
+		b := img.Bounds()
+		step := max(b.Dx()*b.Dy()/medianCutSamples, 1)
+		for i := 0; i < b.Dx()*b.Dy(); i += step {
+			r, g, bl, _ := img.At(b.Min.X+i%b.Dx(), b.Min.Y+i/b.Dx()).RGBA()
+			samples = append(samples, [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(bl >> 8)})
+		}
+	}
+
+	boxes := [][][3]uint8{samples}
+	for len(boxes) < n {
+		split, channel, widest := -1, 0, 0
+		for i, box := range boxes {
+			if c, w := widestChannel(box); w > widest {
+				split, channel, widest = i, c, w
+			}
+		}
+		if split < 0 {
+			break // every box holds a single colour
+		}
+
+		box := boxes[split]
+		sort.Slice(box, func(i, j int) bool { return box[i][channel] < box[j][channel] })
+		mid := len(box) / 2
+		boxes[split] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	pal := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		if len(box) == 0 {
+			continue
+		}
+		var sum [3]int
+		for _, c := range box {
+			sum[0] += int(c[0])
+			sum[1] += int(c[1])
+			sum[2] += int(c[2])
+		}
+		n := len(box)
+		pal = append(pal, color.RGBA{uint8(sum[0] / n), uint8(sum[1] / n), uint8(sum[2] / n), 0xFF})
+	}
+	if len(pal) == 0 {
+		pal = append(pal, color.RGBA{A: 0xFF})
+	}
+
+	return pal
+}
+
+// widestChannel returns the channel with the largest range of values in
+// box, and that range.
+func widestChannel(box [][3]uint8) (channel, width int) {
+	if len(box) < 2 {
+		return 0, 0
+	}
+	lo, hi := box[0], box[0]
+	for _, c := range box[1:] {
+		for k := range 3 {
+			lo[k] = min(lo[k], c[k])
+			hi[k] = max(hi[k], c[k])
+		}
+	}
+	for k := range 3 {
+		if w := int(hi[k]) - int(lo[k]); w > width {
+			channel, width = k, w
+		}
+	}
+	return channel, width
+}
+
+// quantize sets each pixel of dst to the colour of pal nearest the
+// corresponding pixel of src, caching the lookups.
+func quantize(dst *image.Paletted, src image.Image, pal color.Palette) {
+	sb := src.Bounds()
+	cache := make(map[uint32]uint8)
+	for y := range sb.Dy() {
+		for x := range sb.Dx() {
+			r, g, b, _ := src.At(sb.Min.X+x, sb.Min.Y+y).RGBA()
+			key := r>>8<<16 | g>>8<<8 | b>>8
+			idx, ok := cache[key]
+			if !ok {
+				idx = uint8(pal.Index(color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xFF}))
+				cache[key] = idx
+			}
+			dst.Pix[y*dst.Stride+x] = idx
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package mpo_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+	"time"
+
+	"github.com/donatj/mpo"
+)
+
+func solid(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestConvertToWiggle(t *testing.T) {
+	red, green, blue := color.RGBA{200, 0, 0, 255}, color.RGBA{0, 200, 0, 255}, color.RGBA{0, 0, 200, 255}
+	m := &mpo.MPO{Image: []image.Image{solid(40, 30, red), solid(40, 30, green), solid(40, 30, blue)}}
+
+	g, err := m.ConvertToWiggle(&mpo.WiggleOptions{Delay: 200 * time.Millisecond, PingPong: true, AllViews: true})
+	if err != nil {
+		t.Fatalf("ConvertToWiggle failed: %v", err)
+	}
+
+	want := []color.Color{red, green, blue, green}
+	if len(g.Image) != len(want) {
+		t.Fatalf("got %d GIF frames, want %d", len(g.Image), len(want))
+	}
+	for i, c := range want {
+		if got := g.Image[i].At(10, 10); got != c {
+			t.Errorf("frame %d = %v, want %v", i, got, c)
+		}
+		if g.Delay[i] != 20 {
+			t.Errorf("frame %d delay = %d, want 20", i, g.Delay[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatalf("gif.EncodeAll failed: %v", err)
+	}
+	if _, err := gif.DecodeAll(&buf); err != nil {
+		t.Fatalf("gif.DecodeAll failed: %v", err)
+	}
+
+	// without AllViews only the default pair is animated
+	m.Representative = 1
+	if g, err = m.ConvertToWiggle(nil); err != nil {
+		t.Fatalf("ConvertToWiggle failed: %v", err)
+	}
+	if len(g.Image) != 2 || g.Image[0].At(10, 10) != green || g.Image[1].At(10, 10) != blue {
+		t.Errorf("default pair wiggle has %d frames, want green then blue", len(g.Image))
+	}
+}
+
+func TestConvertToWiggleOptions(t *testing.T) {
+	grad := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for y := range 100 {
+		for x := range 200 {
+			grad.Set(x, y, color.RGBA{uint8(x), uint8(y * 2), 128, 255})
+		}
+	}
+	m := &mpo.MPO{Image: []image.Image{grad, grad}}
+
+	g, err := m.ConvertToWiggle(&mpo.WiggleOptions{Width: 100, Colors: 16, Dither: true})
+	if err != nil {
+		t.Fatalf("ConvertToWiggle failed: %v", err)
+	}
+	if len(g.Image) != 2 {
+		t.Errorf("got %d GIF frames, want 2", len(g.Image))
+	}
+	if b := g.Image[0].Bounds(); b.Dx() != 100 || b.Dy() != 50 {
+		t.Errorf("frame size = %v, want 100x50", b)
+	}
+	if n := len(g.Image[0].Palette); n > 16 {
+		t.Errorf("palette has %d colours, want at most 16", n)
+	}
+	if g.Delay[0] != 15 {
+		t.Errorf("default delay = %d, want 15", g.Delay[0])
+	}
+
+	mixed := &mpo.MPO{Image: []image.Image{grad, solid(10, 10, color.Black)}}
+	if _, err := mixed.ConvertToWiggle(nil); !errors.Is(err, mpo.ErrInconsistentBounds) {
+		t.Errorf("frames of different sizes: err = %v, want ErrInconsistentBounds", err)
+	}
+}
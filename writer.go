@@ -2,172 +2,353 @@ package mpo
 
 import (
 	"bytes"
-	"encoding/binary"
 	"errors"
+	"fmt"
+	"image"
 	"image/jpeg"
 	"io"
+
+	"golang.org/x/image/draw"
+)
+
+// Limits imposed on encoded frames by the JPEG and MPF specifications.
+const (
+	// MaxImageDimension is the largest width or height a JPEG frame header
+	// can record.
+	MaxImageDimension = 0xFFFF
+
+	// MaxImages is the largest number of frames whose MP Entries fit in the
+	// single APP2/MPF segment of the first image.
+	MaxImages = (maxSegmentLength - (mpfIndexHeaderSize + mpfAttrIFDSize - 2)) / mpEntrySize
+
+	maxSegmentLength = 0xFFFF     // JPEG marker segment length field
+	maxUint32        = 0xFFFFFFFF // MP Entry size and offset fields
 )
 
-// EncodeAll encodes all images in m into a Baseline‑MP MPO and writes it to w.
+// ErrTooLarge indicates that the frames given to the encoder exceed a size,
+// offset or count limit of the JPEG or MPF specifications.
+var ErrTooLarge = errors.New("exceeds mpo format limits")
+
+// EncodeOptions are the parameters used by EncodeAllWithOptions.
+type EncodeOptions struct {
+	// JPEG is used to encode every frame. If nil, a quality of 90 is used.
+	JPEG *jpeg.Options
+
+	// Representative is the index in MPO.Image of the frame flagged as the
+	// representative image. It is written as the physical first JPEG, so
+	// viewers without MPF support display it, and every frame records its
+	// position in MPO.Image as its MP Individual Image Number so DecodeAll
+	// restores the original order.
+	Representative int
+
+	// LargeThumbnails selects the preview frames generated from the
+	// representative image and linked to it as dependent images.
+	LargeThumbnails LargeThumbnail
+
+	// Exif, if non-nil, adds a minimal APP1/Exif segment ahead of the
+	// APP2/MPF segment of every frame, as DCF based viewers expect.
+	Exif *ExifOptions
+}
+
+// LargeThumbnail selects Large Thumbnail images, preview frames which
+// viewers can display instead of decoding the full resolution frame. Values
+// may be combined.
+type LargeThumbnail int
+
+const (
+	// ThumbnailVGA is a preview fitting 640×480, MP type 0x010001.
+	ThumbnailVGA LargeThumbnail = 1 << iota
+
+	// ThumbnailFullHD is a preview fitting 1920×1080, MP type 0x010002.
+	ThumbnailFullHD
+)
+
+// largeThumbnails lists the Large Thumbnail classes in the order they are
+// written. A thumbnail is omitted if the representative image already fits.
+var largeThumbnails = []struct {
+	flag          LargeThumbnail
+	mpType        uint32
+	width, height int
+}{
+	{ThumbnailVGA, mpTypeLargeThumbVGA, 640, 480},
+	{ThumbnailFullHD, mpTypeLargeThumbFullHD, 1920, 1080},
+}
+
+// encFrame is a frame in the physical order it is written.
+type encFrame struct {
+	img  image.Image
+	num  uint32 // MP Individual Image Number
+	attr uint32 // MP Entry individual image attribute
+}
+
+// EncodeAll encodes all images in m into an MPO and writes it to w, flagging
+// m.Representative as the representative image.
+//
+// The frames are checked with ValidateFrames before encoding and the encoded
+// JPEG sizes with ValidateSizes, so an error wrapping ErrTooLarge is returned
+// rather than writing a file with wrapped-around offsets.
 func EncodeAll(w io.Writer, m *MPO, o *jpeg.Options) error {
+	opts := &EncodeOptions{JPEG: o}
+	if m != nil {
+		opts.Representative = m.Representative
+	}
+	return EncodeAllWithOptions(w, m, opts)
+}
+
+// EncodeAllWithOptions encodes all images in m into an MPO as configured by
+// opts and writes it to w. A nil opts behaves as EncodeAll
+// with nil jpeg.Options.
+//
+// Large Thumbnails are written after every frame of m, flagged as dependent
+// children and listed as Dependent Image entries of the representative image.
+// m.BaseViewpoint is recorded as the Base Viewpoint Number of every frame, or
+// the representative image if it is zero.
+func EncodeAllWithOptions(w io.Writer, m *MPO, opts *EncodeOptions) error {
+	if opts == nil {
+		opts = &EncodeOptions{}
+	}
+	o := opts.JPEG
 	if o == nil {
 		o = &jpeg.Options{Quality: 90}
 	}
 
-	// ── JPEG‑encode every image ────────────────────────────────────────────────
-	bufs := make([][]byte, len(m.Image))
-	sizes := make([]uint32, len(m.Image))
-	for i, img := range m.Image {
-		var b bytes.Buffer
-		if err := jpeg.Encode(&b, img, o); err != nil {
-			return err
-		}
-		bufs[i] = b.Bytes()
-		sizes[i] = uint32(b.Len())
+	if err := ValidateFrames(m); err != nil {
+		return err
 	}
-	if len(bufs) == 0 {
-		return errors.New("no images to encode")
+	if opts.Representative < 0 || opts.Representative >= len(m.Image) {
+		return fmt.Errorf("representative image %d out of range [0, %d)", opts.Representative, len(m.Image))
+	}
+	if m.BaseViewpoint < 0 || m.BaseViewpoint > len(m.Image) {
+		return fmt.Errorf("base viewpoint %d out of range [1, %d]", m.BaseViewpoint, len(m.Image))
 	}
 
-	first := bufs[0]
-	if !bytes.HasPrefix(first, []byte{mpojpgMKR, mpojpgSOI}) { // SOI marker
-		return errors.New("first image missing SOI")
+	// MP Individual Image Number of the base viewpoint
+	base := uint32(opts.Representative + 1)
+	if m.BaseViewpoint > 0 {
+		base = uint32(m.BaseViewpoint)
 	}
 
-	// ── build MPF segment once we know its size --------------------------------
-	tmp, _ := buildMPFSegment(make([]uint32, len(sizes)), sizes)
-	mpfSize := len(tmp)
+	// physical order: the representative image first, the rest as given,
+	// followed by any large thumbnails of the representative image
+	frames := make([]encFrame, 0, len(m.Image)+2)
+	frames = append(frames, encFrame{
+		img:  m.Image[opts.Representative],
+		num:  uint32(opts.Representative + 1),
+		attr: mpTypeBaseline | flagRepresentative,
+	})
+	// only the representative image may be a Baseline MP Primary Image
+	rest := uint32(mpTypeDisparity)
+	if len(m.Image) > 2 {
+		rest = mpTypeMultiAngle
+	}
+	for i, img := range m.Image {
+		if i != opts.Representative {
+			frames = append(frames, encFrame{img: img, num: uint32(i + 1), attr: rest})
+		}
+	}
 
-	// offsets are relative to MP Endian field (see spec §5.2.3.3.3)
-	jfifLen := uint32(findJFIFEnd(first[2:])) // 0 if none
-	posEndian := uint32(2) + jfifLen + 8      // SOI + JFIF + 8 bytes
-	offsets := make([]uint32, len(bufs))
-	filePos := uint32(len(first)) + uint32(mpfSize) // size of first JPEG + MPF
-	for i := 1; i < len(bufs); i++ {
-		offsets[i] = filePos - posEndian
-		filePos += uint32(len(bufs[i]))
+	var deps []uint16
+	for _, t := range largeThumbnails {
+		if opts.LargeThumbnails&t.flag == 0 {
+			continue
+		}
+		thumb := scaleToFit(frames[0].img, t.width, t.height)
+		if thumb == nil {
+			continue
+		}
+		frames = append(frames, encFrame{img: thumb, num: frames[0].num, attr: t.mpType | flagDependentChild})
+		deps = append(deps, uint16(len(frames))) // MP Entry numbers count from 1
+	}
+	if len(deps) > 0 {
+		frames[0].attr |= flagDependentParent
+	}
+
+	// ── JPEG‑encode every image ────────────────────────────────────────────────
+	bufs := make([][]byte, len(frames))
+	exifs := make([][]byte, len(frames))
+	lens := make([]int64, len(frames))
+	for i, f := range frames {
+		var b bytes.Buffer
+		if err := jpeg.Encode(&b, f.img, o); err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(b.Bytes(), []byte{mpojpgMKR, mpojpgSOI}) { // SOI marker
+			return fmt.Errorf("image %d missing SOI", f.num-1)
+		}
+		bufs[i] = b.Bytes()
+		if opts.Exif != nil {
+			exif, err := buildExifSegment(opts.Exif, f.img.Bounds())
+			if err != nil {
+				return err
+			}
+			exifs[i] = exif
+		}
+		lens[i] = int64(b.Len() + len(exifs[i]))
 	}
-	// first image must be 0
-	offsets[0] = 0
 
-	mpfSeg, err := buildMPFSegment(offsets, sizes)
+	prefixLen := findJFIFEnd(bufs[0][2:]) + len(exifs[0]) // JFIF and Exif, 0 if none
+	offsets, sizes, err := layout(lens, prefixLen)
 	if err != nil {
 		return err
 	}
 
-	// ── write final MPO stream --------------------------------------------------
-	if _, err := w.Write([]byte{mpojpgMKR, mpojpgSOI}); err != nil { // SOI
-		return err
+	entries := make([]mpEntry, len(frames))
+	for i, f := range frames {
+		entries[i] = mpEntry{
+			attr:   f.attr,
+			size:   sizes[i],
+			offset: offsets[i],
+		}
+	}
+	if len(deps) > 0 {
+		entries[0].dep1 = deps[0]
+	}
+	if len(deps) > 1 {
+		entries[0].dep2 = deps[1]
 	}
-	if jfifLen > 0 {
-		if _, err := w.Write(first[2 : 2+jfifLen]); err != nil {
+
+	// ── write final MPO stream --------------------------------------------------
+	for i, buf := range bufs {
+		var seg []byte
+		if i == 0 {
+			seg = buildMPFSegment(entries, frames[i].num, base)
+		} else {
+			seg = buildAttrSegment(frames[i].num, base)
+		}
+
+		if err := writeWithSegments(w, buf, exifs[i], seg); err != nil {
 			return err
 		}
 	}
-	if _, err := w.Write(mpfSeg); err != nil { // APP2/MPF
+	return nil
+}
+
+// writeWithSegments writes the JPEG in buf to w with the exif and mpf
+// segments inserted, in that order, after the SOI and any APP0/JFIF segment.
+func writeWithSegments(w io.Writer, buf, exif, mpf []byte) error {
+	split := 2 + findJFIFEnd(buf[2:])
+	if _, err := w.Write(buf[:split]); err != nil { // SOI + JFIF
 		return err
 	}
-	startRest := 2 + int(jfifLen)
-	if _, err := w.Write(first[startRest:]); err != nil { // rest of first JPEG
+	if _, err := w.Write(exif); err != nil { // APP1/Exif
 		return err
 	}
-	for i := 1; i < len(bufs); i++ { // remaining images
-		if _, err := w.Write(bufs[i]); err != nil {
-			return err
+	if _, err := w.Write(mpf); err != nil { // APP2/MPF
+		return err
+	}
+	_, err := w.Write(buf[split:]) // rest of JPEG
+	return err
+}
+
+// ValidateFrames reports whether the frames of m can be encoded as an MPO,
+// without encoding them. It checks the frame count against MaxImages and
+// every frame's dimensions against MaxImageDimension.
+//
+// Limits that depend on the encoded JPEG data are checked by ValidateSizes.
+func ValidateFrames(m *MPO) error {
+	if m == nil || len(m.Image) == 0 {
+		return errors.New("no images to encode")
+	}
+	if len(m.Image) > MaxImages {
+		return fmt.Errorf("%d images, MP Index IFD holds at most %d: %w", len(m.Image), MaxImages, ErrTooLarge)
+	}
+
+	for i, img := range m.Image {
+		if img == nil {
+			return fmt.Errorf("image %d is nil", i)
+		}
+		b := img.Bounds()
+		if b.Empty() {
+			return fmt.Errorf("image %d has empty bounds %v", i, b)
+		}
+		if b.Dx() > MaxImageDimension || b.Dy() > MaxImageDimension {
+			return fmt.Errorf("image %d is %dx%d, JPEG frames are limited to %d pixels per side: %w",
+				i, b.Dx(), b.Dy(), MaxImageDimension, ErrTooLarge)
 		}
 	}
+
 	return nil
 }
 
-const (
-	tagMPFVersion  = 0xB000
-	tagNumImages   = 0xB001
-	tagMPImageList = 0xB002
-	typeUNDEFINED  = 7
-	typeLONG       = 4
-	tiffHeaderSize = 8
-)
+// ValidateSizes reports whether JPEG frames of the given byte lengths, in
+// file order, can be combined into an MPO. It checks that the APP2/MPF
+// segment fits its 16-bit length field and that every MP Entry size and
+// offset fits its 32-bit field once the segments have been inserted.
+//
+// lens include any APP1/Exif segment but not the APP2/MPF segments.
+// prefixLen is the length of the APP0/JFIF and APP1/Exif segments that
+// precede the APP2/MPF segment in the first frame, as offsets are measured
+// from within that segment; 0 checks the offsets as if there were none.
+func ValidateSizes(lens []int64, prefixLen int) error {
+	_, _, err := layout(lens, prefixLen)
+	return err
+}
 
-const (
-	flagRepresentative = 0x20000000
-	mpTypeBaseline     = 0x00030000 // Baseline MP primary image
-)
+// layout computes the MP Entry offsets and sizes for JPEG frames of the given
+// lengths, in file order, once their APP2/MPF segments are inserted.
+// prefixLen is the length of the APP0/JFIF and APP1/Exif segments the MPF
+// segment is inserted after in the first frame.
+func layout(lens []int64, prefixLen int) (offsets, sizes []uint32, err error) {
+	if len(lens) == 0 {
+		return nil, nil, errors.New("no images to encode")
+	}
+
+	mpfSize := mpfIndexSegmentSize(len(lens))
+	if mpfSize-2 > maxSegmentLength {
+		return nil, nil, fmt.Errorf("APP2/MPF segment for %d images is %d bytes, limit is %d: %w",
+			len(lens), mpfSize-2, maxSegmentLength, ErrTooLarge)
+	}
+
+	// offsets are relative to MP Endian field (see spec §5.2.3.3.3)
+	posEndian := 2 + prefixLen + 8 // SOI + JFIF + Exif + APP2 marker, length and "MPF\0"
+
+	offsets = make([]uint32, len(lens))
+	sizes = make([]uint32, len(lens))
+	var filePos int64
+	for i, l := range lens {
+		if l <= 0 {
+			return nil, nil, fmt.Errorf("image %d has invalid length %d", i, l)
+		}
 
-// buildMPFSegment constructs a valid APP2/MPF segment.
-func buildMPFSegment(offsets, sizes []uint32) ([]byte, error) {
-	if len(offsets) != len(sizes) {
-		return nil, errors.New("offset and size counts differ")
-	}
-
-	numImg := uint32(len(offsets))
-	numTags := uint16(3)
-
-	b := new(bytes.Buffer)
-	// APP2 marker & length placeholder
-	b.Write([]byte{0xFF, 0xE2, 0x00, 0x00})
-	// "MPF\0"
-	b.Write([]byte{'M', 'P', 'F', 0x00})
-
-	// TIFF header (little‑endian)
-	b.Write([]byte("II"))
-	binary.Write(b, binary.LittleEndian, uint16(0x002A))
-	binary.Write(b, binary.LittleEndian, uint32(8)) // first IFD after header
-
-	// IFD entry count
-	binary.Write(b, binary.LittleEndian, numTags)
-
-	// ── tag 0xb000 – MPFVersion ("0100") inline ――――――――――――――――――――――――――――――
-	binary.Write(b, binary.LittleEndian, uint16(tagMPFVersion))
-	binary.Write(b, binary.LittleEndian, uint16(typeUNDEFINED))
-	binary.Write(b, binary.LittleEndian, uint32(4))
-	b.Write([]byte{'0', '1', '0', '0'})
-
-	// ── tag 0xb001 – NumberOfImages ―――――――――――――――――――――――――――――――――――――
-	binary.Write(b, binary.LittleEndian, uint16(tagNumImages))
-	binary.Write(b, binary.LittleEndian, uint16(typeLONG))
-	binary.Write(b, binary.LittleEndian, uint32(1))
-	binary.Write(b, binary.LittleEndian, numImg)
-
-	// ── tag 0xb002 – MPImageList (offset to 16‑byte entries) ――――――――――――――――
-	entryOffset := uint32(tiffHeaderSize + 2 + uint32(numTags)*12 + 4)
-	binary.Write(b, binary.LittleEndian, uint16(tagMPImageList))
-	binary.Write(b, binary.LittleEndian, uint16(typeUNDEFINED))
-	binary.Write(b, binary.LittleEndian, uint32(numImg*16))
-	binary.Write(b, binary.LittleEndian, entryOffset)
-
-	// next‑IFD offset = 0
-	binary.Write(b, binary.LittleEndian, uint32(0))
-
-	// ── MP Entry array ―――――――――――――――――――――――――――――――――――――――――――――――――――
-	for i := range offsets {
-		attr := mpTypeBaseline
+		size := l
 		if i == 0 {
-			attr |= flagRepresentative
+			size += int64(mpfSize) // the first image carries the MP Index IFD
+		} else {
+			size += mpfAttrSegmentSize
+			// first image must be 0
+			offset := filePos - int64(posEndian)
+			if offset > maxUint32 {
+				return nil, nil, fmt.Errorf("image %d starts at offset %d, MP Entry offsets are limited to %d: %w",
+					i, offset, int64(maxUint32), ErrTooLarge)
+			}
+			offsets[i] = uint32(offset)
 		}
-		binary.Write(b, binary.LittleEndian, uint32(attr))
-		binary.Write(b, binary.LittleEndian, sizes[i])
-		binary.Write(b, binary.LittleEndian, offsets[i])
-		binary.Write(b, binary.LittleEndian, uint16(0)) // Dep‑1
-		binary.Write(b, binary.LittleEndian, uint16(0)) // Dep‑2
+		if size > maxUint32 {
+			return nil, nil, fmt.Errorf("image %d is %d bytes, MP Entry sizes are limited to %d: %w",
+				i, size, int64(maxUint32), ErrTooLarge)
+		}
+		sizes[i] = uint32(size)
+		filePos += size
 	}
 
-	// fill in APP2 length (bytes after marker)
-	data := b.Bytes()
-	segLen := len(data) - 2
-	data[2] = byte(segLen >> 8)
-	data[3] = byte(segLen)
-
-	return data, nil
+	return offsets, sizes, nil
 }
 
-// findJFIFEnd returns the length of an APP0/JFIF segment immediately after SOI.
-func findJFIFEnd(d []byte) int {
-	if len(d) < 4 || d[0] != 0xFF || d[1] != 0xE0 { // APP0?
-		return 0
+// scaleToFit returns img scaled down to fit within width×height, keeping its
+// aspect ratio, or nil if it already fits.
+func scaleToFit(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= width && b.Dy() <= height {
+		return nil
 	}
-	l := int(d[2])<<8 | int(d[3])
-	if l >= 2 && len(d) >= l {
-		return l
+
+	w, h := width, b.Dy()*width/b.Dx()
+	if h > height {
+		w, h = b.Dx()*height/b.Dy(), height
 	}
-	return 0
+
+	dst := image.NewRGBA(image.Rect(0, 0, max(w, 1), max(h, 1)))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+
+	return dst
 }
@@ -0,0 +1,65 @@
+package mpo
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEncodeAll_MPTypes(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := range 16 {
+		for x := range 16 {
+			img.Set(x, y, color.RGBA{uint8(x * 16), uint8(y * 16), uint8(x * y), 255})
+		}
+	}
+
+	// the representative Baseline MP Primary Image, then Multi-Frame
+	// Disparity images for a pair or Multi-Angle images for more frames
+	tests := []struct {
+		frames int
+		rest   uint32
+	}{
+		{2, mpTypeDisparity},
+		{3, mpTypeMultiAngle},
+	}
+
+	for _, tc := range tests {
+		frames := make([]image.Image, tc.frames)
+		for i := range frames {
+			frames[i] = img
+		}
+
+		var buf bytes.Buffer
+		if err := EncodeAll(&buf, &MPO{Image: frames}, nil); err != nil {
+			t.Fatalf("EncodeAll failed: %v", err)
+		}
+
+		segs, err := readSegments(bytes.NewReader(buf.Bytes()), 0)
+		if err != nil {
+			t.Fatalf("%d frames: readSegments failed: %v", tc.frames, err)
+		}
+		seg, ok := findMPF(segs)
+		if !ok {
+			t.Fatalf("%d frames: no APP2/MPF segment in the first image", tc.frames)
+		}
+		mpf, err := parseMPF(seg.data)
+		if err != nil {
+			t.Fatalf("%d frames: parseMPF failed: %v", tc.frames, err)
+		}
+		if len(mpf.entries) != tc.frames {
+			t.Fatalf("%d frames: %d MP Entries", tc.frames, len(mpf.entries))
+		}
+
+		for i, e := range mpf.entries {
+			want := tc.rest
+			if i == 0 {
+				want = mpTypeBaseline | flagRepresentative
+			}
+			if e.attr != want {
+				t.Errorf("%d frames: entry %d attribute %#08x, want %#08x", tc.frames, i, e.attr, want)
+			}
+		}
+	}
+}
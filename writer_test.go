@@ -3,9 +3,12 @@ package mpo_test
 
 import (
 	"bytes"
+	"errors"
 	"image"
 	"image/color"
 	"image/jpeg"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/donatj/mpo"
@@ -54,3 +57,210 @@ func TestEncodeAll_RoundTrip(t *testing.T) {
 		t.Fatalf("unexpected dimensions: got %dx%d, want 10x10", cfg.Width, cfg.Height)
 	}
 }
+
+func TestValidateFrames(t *testing.T) {
+	small := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	wide := image.NewGray(image.Rect(0, 0, mpo.MaxImageDimension+1, 1))
+
+	tests := []struct {
+		name    string
+		m       *mpo.MPO
+		wantErr bool
+		tooBig  bool
+	}{
+		{"nil", nil, true, false},
+		{"empty", &mpo.MPO{}, true, false},
+		{"nil frame", &mpo.MPO{Image: []image.Image{small, nil}}, true, false},
+		{"wide frame", &mpo.MPO{Image: []image.Image{small, wide}}, true, true},
+		{"too many frames", &mpo.MPO{Image: make([]image.Image, mpo.MaxImages+1)}, true, true},
+		{"ok", &mpo.MPO{Image: []image.Image{small, small}}, false, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := mpo.ValidateFrames(tc.m)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ValidateFrames() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got := errors.Is(err, mpo.ErrTooLarge); got != tc.tooBig {
+				t.Fatalf("errors.Is(%v, ErrTooLarge) = %v, want %v", err, got, tc.tooBig)
+			}
+		})
+	}
+}
+
+func TestValidateSizes(t *testing.T) {
+	if err := mpo.ValidateSizes([]int64{1000, 1000}, 0); err != nil {
+		t.Fatalf("ValidateSizes() unexpected error: %v", err)
+	}
+
+	// The first frame grows by the MPF segment and would overflow its size field.
+	if err := mpo.ValidateSizes([]int64{math.MaxUint32 - 10, 1000}, 0); !errors.Is(err, mpo.ErrTooLarge) {
+		t.Fatalf("ValidateSizes() oversized first frame error = %v, want ErrTooLarge", err)
+	}
+
+	// Each frame fits, but the offset of the third does not.
+	if err := mpo.ValidateSizes([]int64{math.MaxUint32 / 2, math.MaxUint32 / 2, 1000}, 0); !errors.Is(err, mpo.ErrTooLarge) {
+		t.Fatalf("ValidateSizes() overflowing offset error = %v, want ErrTooLarge", err)
+	}
+
+	// Frames larger than an int on 32-bit targets can still be checked.
+	if err := mpo.ValidateSizes([]int64{math.MaxInt32 + 1000, 1000}, 0); err != nil {
+		t.Fatalf("ValidateSizes() unexpected error for a frame over 2 GiB: %v", err)
+	}
+}
+
+func TestEncodeAllWithOptions_Representative(t *testing.T) {
+	colors := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}}
+	frames := make([]image.Image, len(colors))
+	for i, c := range colors {
+		img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+		for x := range 8 {
+			for y := range 8 {
+				img.Set(x, y, c)
+			}
+		}
+		frames[i] = img
+	}
+
+	var buf bytes.Buffer
+	err := mpo.EncodeAllWithOptions(&buf, &mpo.MPO{Image: frames}, &mpo.EncodeOptions{Representative: 1})
+	if err != nil {
+		t.Fatalf("EncodeAllWithOptions failed: %v", err)
+	}
+
+	// A plain JPEG decoder sees only the representative (green) frame.
+	first, err := jpeg.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("jpeg.Decode failed: %v", err)
+	}
+	if r, g, _, _ := first.At(4, 4).RGBA(); g>>8 < 200 || r>>8 > 50 {
+		t.Errorf("first JPEG is not the representative frame, got %v", first.At(4, 4))
+	}
+
+	// DecodeAll restores the original order.
+	decoded, err := mpo.DecodeAll(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("DecodeAll failed: %v", err)
+	}
+	if got := len(decoded.Image); got != len(colors) {
+		t.Fatalf("expected %d images, got %d", len(colors), got)
+	}
+	for i, want := range colors {
+		r, g, b, _ := decoded.Image[i].At(4, 4).RGBA()
+		if absDiff(uint8(r>>8), want.R) > 40 || absDiff(uint8(g>>8), want.G) > 40 || absDiff(uint8(b>>8), want.B) > 40 {
+			t.Errorf("frame %d = %v, want approximately %v", i, decoded.Image[i].At(4, 4), want)
+		}
+	}
+
+	err = mpo.EncodeAllWithOptions(&buf, &mpo.MPO{Image: frames}, &mpo.EncodeOptions{Representative: 3})
+	if err == nil {
+		t.Fatal("expected error for out of range representative, got nil")
+	}
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestEncodeAllWithOptions_LargeThumbnails(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 800, 600))
+	m := &mpo.MPO{Image: []image.Image{img, img}}
+
+	var plain, thumbs bytes.Buffer
+	if err := mpo.EncodeAll(&plain, m, nil); err != nil {
+		t.Fatalf("EncodeAll failed: %v", err)
+	}
+	err := mpo.EncodeAllWithOptions(&thumbs, m, &mpo.EncodeOptions{LargeThumbnails: mpo.ThumbnailVGA | mpo.ThumbnailFullHD})
+	if err != nil {
+		t.Fatalf("EncodeAllWithOptions failed: %v", err)
+	}
+
+	// Only the VGA preview is written, the frames already fit Full-HD.
+	if got := bytes.Count(thumbs.Bytes(), []byte{0xFF, 0xD8, 0xFF}); got != 3 {
+		t.Errorf("found %d JPEG streams, want 3", got)
+	}
+	if thumbs.Len() <= plain.Len() {
+		t.Errorf("thumbnail MPO is %d bytes, not larger than %d", thumbs.Len(), plain.Len())
+	}
+
+	// DecodeAll skips the preview.
+	decoded, err := mpo.DecodeAll(bytes.NewReader(thumbs.Bytes()))
+	if err != nil {
+		t.Fatalf("DecodeAll failed: %v", err)
+	}
+	if got := len(decoded.Image); got != 2 {
+		t.Fatalf("expected 2 images, got %d", got)
+	}
+	if b := decoded.Image[0].Bounds(); b.Dx() != 800 || b.Dy() != 600 {
+		t.Errorf("frame 0 is %dx%d, want 800x600", b.Dx(), b.Dy())
+	}
+}
+
+func TestEncodeAllWithOptions_Exif(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	m := &mpo.MPO{Image: []image.Image{img, img}}
+
+	var buf bytes.Buffer
+	err := mpo.EncodeAllWithOptions(&buf, m, &mpo.EncodeOptions{
+		Exif: &mpo.ExifOptions{Make: "Acme", Model: "Stereo 1"},
+	})
+	if err != nil {
+		t.Fatalf("EncodeAllWithOptions failed: %v", err)
+	}
+
+	// Every frame starts with SOI, APP1/Exif and then APP2/MPF.
+	data := buf.Bytes()
+	frames := bytes.Split(data, []byte{0xFF, 0xD8, 0xFF, 0xE1})
+	if len(frames) != 3 || len(frames[0]) != 0 {
+		t.Fatalf("found %d frames starting with APP1, want 2", len(frames)-1)
+	}
+	for i, f := range frames[1:] {
+		if !bytes.HasPrefix(f[2:], []byte("Exif\x00\x00II")) {
+			t.Errorf("frame %d APP1 is not Exif", i)
+		}
+		l := int(f[0])<<8 | int(f[1])
+		if !bytes.HasPrefix(f[l:], []byte{0xFF, 0xE2}) || !bytes.HasPrefix(f[l+4:], []byte("MPF\x00")) {
+			t.Errorf("frame %d APP1/Exif is not followed by APP2/MPF", i)
+		}
+	}
+	if !bytes.Contains(data, []byte("Stereo 1\x00")) {
+		t.Error("camera model not recorded")
+	}
+
+	decoded, err := mpo.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DecodeAll failed: %v", err)
+	}
+	if got := len(decoded.Image); got != 2 {
+		t.Fatalf("expected 2 images, got %d", got)
+	}
+
+	issues, err := mpo.Validate(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	// with Exif segments the output should satisfy the validator entirely
+	for _, is := range issues {
+		t.Errorf("unexpected issue: %s", is)
+	}
+}
+
+func TestEncodeAllWithOptions_ExifTooLarge(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	m := &mpo.MPO{Image: []image.Image{img, img}}
+
+	var buf bytes.Buffer
+	err := mpo.EncodeAllWithOptions(&buf, m, &mpo.EncodeOptions{
+		Exif: &mpo.ExifOptions{Make: strings.Repeat("A", 70000)},
+	})
+	if !errors.Is(err, mpo.ErrTooLarge) {
+		t.Fatalf("EncodeAllWithOptions() oversized Make error = %v, want ErrTooLarge", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("wrote %d bytes despite the error", buf.Len())
+	}
+}